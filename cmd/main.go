@@ -2,13 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
+	"expvar"
 	"github.com/gin-gonic/gin"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 	"wb-task-L0/pkg/cache"
+	"wb-task-L0/pkg/codec"
+	"wb-task-L0/pkg/invalidation"
 	"wb-task-L0/pkg/kafka"
 	"wb-task-L0/pkg/models"
+	"wb-task-L0/pkg/outbox"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -31,34 +38,98 @@ func main() {
 		logrus.Fatalf("error loading env variables: %s", err.Error())
 	}
 
-	db, err := repository.NewPostgresDB(repository.Config{
+	dbConfig := repository.Config{
 		Host:     viper.GetString("db.host"),
 		Port:     viper.GetString("db.port"),
 		Username: viper.GetString("db.username"),
 		DBName:   viper.GetString("db.dbname"),
 		SSLMode:  viper.GetString("db.sslmode"),
 		Password: os.Getenv("DB_PASSWORD"),
-	})
+	}
+	db, err := repository.NewPostgresDB(dbConfig)
 	if err != nil {
 		logrus.Fatalf("failed to initialize db: %s", err.Error())
 	}
 
 	repos := repository.NewRepository(db)
-	orderCache := cache.NewCache()
 
-	orders, err := repos.Order.GetAll()
-	if err != nil {
-		logrus.Fatalf("failed to load orders: %v", err)
+	var invalidations invalidation.Publisher = invalidation.Nop{}
+	var bus *invalidation.Bus
+	if viper.GetBool("invalidation.enabled") {
+		bus = invalidation.NewBus(db, invalidation.Config{
+			DSN:          dbConfig.DSN(),
+			MinReconnect: viper.GetDuration("invalidation.min_reconnect"),
+			MaxReconnect: viper.GetDuration("invalidation.max_reconnect"),
+		})
+		invalidations = bus
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		runReplay(os.Args[2:], repos, invalidations)
+		return
+	}
+
+	orderCache := cache.New(cache.Config{
+		Shards:     viper.GetInt("cache.shards"),
+		MaxEntries: viper.GetInt("cache.max_entries"),
+		MaxBytes:   viper.GetInt64("cache.max_bytes"),
+		Policy:     cache.Policy(viper.GetString("cache.policy")),
+
+		TTL:          viper.GetDuration("cache.ttl"),
+		RefreshAhead: viper.GetDuration("cache.refresh_ahead"),
+		StaleTTL:     viper.GetDuration("cache.stale_ttl"),
+		NegativeTTL:  viper.GetDuration("cache.negative_ttl"),
+	})
+	expvar.Publish("order_cache", expvar.Func(func() any { return orderCache.Stats() }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	warmer := cache.NewWarmer(orderCache, cache.WarmupConfig{
+		BatchSize: viper.GetInt("cache.warmup.batch_size"),
+		MaxOrders: viper.GetInt("cache.warmup.max_orders"),
+		MaxAge:    viper.GetDuration("cache.warmup.max_age"),
+	}, repos.Order.GetPage, repos.Order.Count)
+	expvar.Publish("cache_warmup", expvar.Func(func() any { return warmer.Progress() }))
+	expvar.Publish("cache_rebuild", expvar.Func(func() any { return warmer.RebuildProgress() }))
+	snapshotPath := viper.GetString("cache.snapshot.path")
+	go func() {
+		if err := warmCache(ctx, orderCache, warmer, repos, snapshotPath); err != nil {
+			logrus.Errorf("cache warm-up failed, orders will be loaded on demand: %s", err.Error())
+		}
+	}()
+	go orderCache.StartRefresher(ctx, repos.Order.GetByID)
+	go pruneTombstones(ctx, repos.Order, viper.GetDuration("tombstones.retention"), viper.GetDuration("tombstones.prune_every"))
+	if snapshotPath != "" {
+		go orderCache.StartSnapshots(ctx, snapshotPath, viper.GetDuration("cache.snapshot.interval"))
 	}
-	orderCache.LoadFromDB(orders)
-	logrus.Printf("Cache initialized with %d orders", orderCache.Len())
 
-	services := service.NewService(repos, orderCache)
-	handlers := handler.NewHandler(services)
+	if bus != nil {
+		go func() {
+			h := invalidation.NewCacheHandler(orderCache, repos.Order.GetByID, warmer)
+			if err := bus.Listen(ctx, h); err != nil {
+				logrus.Errorf("cache invalidation listener stopped: %s", err.Error())
+			}
+		}()
+	} else {
+		logrus.Warn("cache invalidation is disabled, other instances may serve deleted orders")
+	}
+
+	services := service.NewService(ctx, repos, orderCache, warmer, invalidations)
+	handlers := handler.NewHandler(services, os.Getenv("ADMIN_TOKEN"))
 
 	router := gin.New()
 	router.Use(gin.Recovery(), gin.Logger())
 
+	router.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+	router.GET("/health", func(c *gin.Context) {
+		p := warmer.Progress()
+		if p.State == cache.WarmupRunning || p.State == cache.WarmupPending {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "warming", "warmup": p})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "warmup": p})
+	})
 	router.Static("/static", "./web")
 	router.GET("/", func(c *gin.Context) {
 		c.File("./web/front.html")
@@ -80,25 +151,80 @@ func main() {
 	if brokerEnv == "" || topicEnv == "" {
 		logrus.Fatal("KAFKA_BROKER or KAFKA_TOPIC is not set in environment")
 	}
-	brokers := []string{brokerEnv}
-	topic := topicEnv
-	groupID := "order-consumers"
 
-	consumer := kafka.NewConsumer(brokers, topic, groupID, repos.Order, orderCache)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	go consumer.Start(ctx)
+	codecs, err := codec.NewRegistry(viper.GetString("kafka.codec"), viper.GetString("kafka.schema_dir"))
+	if err != nil {
+		logrus.Fatalf("failed to initialize codecs: %s", err.Error())
+	}
+
+	consumer := kafka.NewConsumer(kafka.Config{
+		Brokers:  []string{brokerEnv},
+		Topic:    topicEnv,
+		GroupID:  viper.GetString("kafka.group_id"),
+		DLQTopic: os.Getenv("KAFKA_DLQ_TOPIC"),
+		Retry: kafka.RetryPolicy{
+			MaxAttempts:    viper.GetInt("kafka.retry.max_attempts"),
+			InitialBackoff: viper.GetDuration("kafka.retry.initial_backoff"),
+			MaxBackoff:     viper.GetDuration("kafka.retry.max_backoff"),
+		},
+		Codecs:        codecs,
+		Invalidations: invalidations,
+		Workers:       viper.GetInt("kafka.workers"),
+		QueueSize:     viper.GetInt("kafka.queue_size"),
+
+		BatchSize:    viper.GetInt("kafka.batch_size"),
+		BatchTimeout: viper.GetDuration("kafka.batch_timeout"),
+	}, repos.Order, orderCache)
+	consumerDone := make(chan struct{})
+	go func() {
+		consumer.Start(ctx)
+		close(consumerDone)
+	}()
 	logrus.Print("Kafka consumer started")
 
+	var relay *outbox.Relay
+	relayDone := make(chan struct{})
+	if outboxTopic := os.Getenv("OUTBOX_TOPIC"); outboxTopic != "" {
+		relay = outbox.NewRelay(outbox.Config{
+			Brokers:      []string{brokerEnv},
+			Topic:        outboxTopic,
+			PollInterval: viper.GetDuration("outbox.poll_interval"),
+			BatchSize:    viper.GetInt("outbox.batch_size"),
+			Retention:    viper.GetDuration("outbox.retention"),
+			PruneEvery:   viper.GetDuration("outbox.prune_every"),
+		}, repos.Outbox)
+		go func() {
+			relay.Start(ctx)
+			close(relayDone)
+		}()
+		logrus.Print("Outbox relay started")
+	} else {
+		close(relayDone)
+		logrus.Warn("OUTBOX_TOPIC is not set, outbox events will not be published")
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
 	<-quit
 	logrus.Print("Shutting down application...")
 
 	cancel()
+	<-consumerDone
 	if err := consumer.Close(); err != nil {
 		logrus.Errorf("error closing Kafka consumer: %s", err.Error())
 	}
+	<-relayDone
+	if relay != nil {
+		if err := relay.Close(); err != nil {
+			logrus.Errorf("error closing outbox relay: %s", err.Error())
+		}
+	}
+
+	if snapshotPath != "" {
+		if err := orderCache.SaveSnapshot(snapshotPath); err != nil {
+			logrus.Errorf("failed to save cache snapshot: %s", err.Error())
+		}
+	}
 
 	if err := srv.Shutdown(context.Background()); err != nil {
 		logrus.Errorf("error occured on server shutting down: %s", err.Error())
@@ -120,6 +246,68 @@ func main() {
 	logrus.Print("Application shutdown complete")
 }
 
+// warmCache restores the cache from a snapshot and catches up on changes
+// made after it, falling back to a full warm-up when there is no usable
+// snapshot.
+func warmCache(ctx context.Context, c cache.Cache, warmer *cache.Warmer, repos *repository.Repository, snapshotPath string) error {
+	if snapshotPath == "" {
+		return warmer.Run(ctx)
+	}
+
+	watermark, err := c.LoadSnapshot(snapshotPath, viper.GetDuration("cache.snapshot.max_age"))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logrus.Warnf("ignoring cache snapshot: %s", err.Error())
+		}
+		return warmer.Run(ctx)
+	}
+	logrus.Printf("Cache restored from snapshot with %d orders", c.Len())
+
+	// outbox created_at is the transaction start, so look back a little
+	since := watermark.Add(-viper.GetDuration("cache.snapshot.catchup_margin"))
+	return warmer.CatchUp(ctx, since, repos.Outbox.ChangedSince, repos.Order.GetByID)
+}
+
+const tombstonePruneBatch = 1000
+
+// pruneTombstones deletes tombstones older than retention every interval
+// until ctx is done. Zero retention keeps them forever.
+func pruneTombstones(ctx context.Context, repo repository.Order, retention, interval time.Duration) {
+	if retention <= 0 {
+		return
+	}
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		before := time.Now().Add(-retention)
+		var total int64
+		for ctx.Err() == nil {
+			n, err := repo.PruneTombstones(ctx, before, tombstonePruneBatch)
+			if err != nil {
+				logrus.Errorf("failed to prune order tombstones: %s", err.Error())
+				break
+			}
+			total += n
+			if n < tombstonePruneBatch {
+				break
+			}
+		}
+		if total > 0 {
+			logrus.Printf("Pruned %d order tombstones older than %s", total, retention)
+		}
+	}
+}
+
 func initConfig() error {
 	viper.AddConfigPath("configs")
 	viper.SetConfigName("config")
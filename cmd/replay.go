@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"wb-task-L0/pkg/cache"
+	"wb-task-L0/pkg/codec"
+	"wb-task-L0/pkg/invalidation"
+	"wb-task-L0/pkg/kafka"
+	"wb-task-L0/pkg/repository"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// runReplay re-reads KAFKA_TOPIC from the requested position and pushes every
+// message through the regular consumer decode and persist path.
+//
+//	go run ./cmd replay -offset 0
+//	go run ./cmd replay -since 2025-09-01T00:00:00Z -until 2025-09-02T00:00:00Z -dry-run
+//	go run ./cmd replay -partitions 0:120,2:4000
+func runReplay(args []string, repos *repository.Repository, invalidations invalidation.Publisher) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	offset := fs.Int64("offset", -1, "start every partition at this offset")
+	since := fs.String("since", "", "start at the first message at or after this RFC3339 time")
+	until := fs.String("until", "", "stop at messages newer than this RFC3339 time")
+	partitions := fs.String("partitions", "", "comma separated partition:offset pairs, e.g. 0:100,1:250")
+	dryRun := fs.Bool("dry-run", false, "only report what would be inserted")
+	_ = fs.Parse(args)
+
+	broker := os.Getenv("KAFKA_BROKER")
+	topic := os.Getenv("KAFKA_TOPIC")
+	if broker == "" || topic == "" {
+		logrus.Fatal("KAFKA_BROKER or KAFKA_TOPIC is not set in environment")
+	}
+
+	codecs, err := codec.NewRegistry(viper.GetString("kafka.codec"), viper.GetString("kafka.schema_dir"))
+	if err != nil {
+		logrus.Fatalf("failed to initialize codecs: %s", err.Error())
+	}
+
+	cfg := kafka.ReplayConfig{
+		Brokers:    []string{broker},
+		Topic:      topic,
+		FromOffset: *offset,
+		DryRun:     *dryRun,
+		Codecs:     codecs,
+
+		Invalidations: invalidations,
+		Retry: kafka.RetryPolicy{
+			MaxAttempts:    viper.GetInt("kafka.retry.max_attempts"),
+			InitialBackoff: viper.GetDuration("kafka.retry.initial_backoff"),
+			MaxBackoff:     viper.GetDuration("kafka.retry.max_backoff"),
+		},
+	}
+
+	if *since != "" {
+		if cfg.Since, err = time.Parse(time.RFC3339, *since); err != nil {
+			logrus.Fatalf("invalid -since: %s", err.Error())
+		}
+	}
+	if *until != "" {
+		if cfg.Until, err = time.Parse(time.RFC3339, *until); err != nil {
+			logrus.Fatalf("invalid -until: %s", err.Error())
+		}
+	}
+	if *partitions != "" {
+		if cfg.Offsets, err = parsePartitionOffsets(*partitions); err != nil {
+			logrus.Fatalf("invalid -partitions: %s", err.Error())
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+
+	// the replay cache is dropped at exit, it only needs the same bounds as
+	// the service's so a full topic doesn't pile up in memory
+	replayCache := cache.New(cache.Config{
+		Shards:     viper.GetInt("cache.shards"),
+		MaxEntries: viper.GetInt("cache.max_entries"),
+		MaxBytes:   viper.GetInt64("cache.max_bytes"),
+		Policy:     cache.Policy(viper.GetString("cache.policy")),
+	})
+	replayer := kafka.NewReplayer(cfg, repos.Order, replayCache)
+	stats, err := replayer.Run(ctx)
+	logrus.WithFields(logrus.Fields{
+		"read":    stats.Read,
+		"applied": stats.Applied,
+		"skipped": stats.Skipped,
+		"failed":  stats.Failed,
+		"dry_run": cfg.DryRun,
+	}).Print("Replay finished")
+	if err != nil {
+		logrus.Fatalf("replay failed: %s", err.Error())
+	}
+}
+
+func parsePartitionOffsets(s string) (map[int]int64, error) {
+	offsets := make(map[int]int64)
+	for _, pair := range strings.Split(s, ",") {
+		p, o, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("expected partition:offset, got %q", pair)
+		}
+		partition, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("bad partition %q", p)
+		}
+		offset, err := strconv.ParseInt(o, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad offset %q", o)
+		}
+		offsets[partition] = offset
+	}
+	return offsets, nil
+}
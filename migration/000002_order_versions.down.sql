@@ -0,0 +1,2 @@
+ALTER TABLE orders DROP COLUMN IF EXISTS event_time;
+ALTER TABLE orders DROP COLUMN IF EXISTS event_version;
@@ -0,0 +1,7 @@
+-- Версия заказа из upstream и время события для upsert
+ALTER TABLE orders ADD COLUMN event_version BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN event_time TIMESTAMP WITH TIME ZONE;
+
+UPDATE orders SET event_time = date_created;
+
+ALTER TABLE orders ALTER COLUMN event_time SET NOT NULL;
@@ -0,0 +1,2 @@
+-- Удаление outbox
+DROP TABLE IF EXISTS outbox;
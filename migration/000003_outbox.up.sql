@@ -0,0 +1,12 @@
+-- Outbox событий по заказам, пишется в одной транзакции с заказом
+CREATE TABLE outbox (
+                        id            BIGSERIAL PRIMARY KEY,
+                        aggregate_id  VARCHAR NOT NULL,
+                        event_type    VARCHAR NOT NULL,
+                        payload       JSONB NOT NULL,
+                        created_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+                        sent_at       TIMESTAMP WITH TIME ZONE
+);
+
+-- Частичный индекс для выборки неотправленных событий
+CREATE INDEX outbox_pending_idx ON outbox (id) WHERE sent_at IS NULL;
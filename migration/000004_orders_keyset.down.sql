@@ -0,0 +1,2 @@
+-- Удаление индекса keyset-пагинации
+DROP INDEX IF EXISTS orders_date_created_uid_idx;
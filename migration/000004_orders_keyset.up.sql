@@ -0,0 +1,2 @@
+-- Индекс для keyset-пагинации заказов (новые первыми)
+CREATE INDEX orders_date_created_uid_idx ON orders (date_created DESC, order_uid DESC);
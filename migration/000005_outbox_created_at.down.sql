@@ -0,0 +1,2 @@
+-- Удаление индекса по времени создания событий
+DROP INDEX IF EXISTS outbox_created_at_idx;
@@ -0,0 +1,2 @@
+-- Индекс для догрузки изменений после снапшота кэша
+CREATE INDEX outbox_created_at_idx ON outbox (created_at);
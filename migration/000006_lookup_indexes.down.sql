@@ -0,0 +1,4 @@
+-- Удаление индексов поиска заказов
+DROP INDEX IF EXISTS payments_transaction_idx;
+DROP INDEX IF EXISTS orders_customer_id_idx;
+DROP INDEX IF EXISTS orders_track_number_idx;
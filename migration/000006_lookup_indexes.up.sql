@@ -0,0 +1,4 @@
+-- Индексы для поиска заказов по трек-номеру, клиенту и транзакции
+CREATE INDEX orders_track_number_idx ON orders (track_number);
+CREATE INDEX orders_customer_id_idx ON orders (customer_id, date_created DESC) INCLUDE (order_uid);
+CREATE INDEX payments_transaction_idx ON payments (transaction);
@@ -0,0 +1,6 @@
+-- Удаление индексов фильтров списка заказов
+DROP INDEX IF EXISTS orders_delivery_service_idx;
+DROP INDEX IF EXISTS items_brand_status_idx;
+DROP INDEX IF EXISTS items_order_uid_idx;
+DROP INDEX IF EXISTS payments_order_uid_idx;
+DROP INDEX IF EXISTS deliveries_order_uid_idx;
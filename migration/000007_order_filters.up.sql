@@ -0,0 +1,6 @@
+-- Индексы для фильтров списка заказов и подгрузки связанных таблиц
+CREATE INDEX deliveries_order_uid_idx ON deliveries (order_uid);
+CREATE INDEX payments_order_uid_idx ON payments (order_uid, currency, provider);
+CREATE INDEX items_order_uid_idx ON items (order_uid);
+CREATE INDEX items_brand_status_idx ON items (brand, status);
+CREATE INDEX orders_delivery_service_idx ON orders (delivery_service, date_created DESC);
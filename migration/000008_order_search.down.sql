@@ -0,0 +1,5 @@
+-- Удаление индексов поиска
+DROP INDEX IF EXISTS items_name_trgm_idx;
+DROP INDEX IF EXISTS items_name_fts_idx;
+DROP INDEX IF EXISTS deliveries_search_trgm_idx;
+DROP INDEX IF EXISTS deliveries_search_fts_idx;
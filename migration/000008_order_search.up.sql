@@ -0,0 +1,14 @@
+-- Полнотекстовый и нечёткий (триграммы) поиск по доставке и товарам.
+-- Конфигурация 'simple' без стемминга: имена, города и адреса бывают на
+-- разных языках. Выражения должны совпадать с запросом в OrderRepo.Search.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX deliveries_search_fts_idx ON deliveries USING GIN (
+    to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(city, '') || ' ' || coalesce(address, '') || ' ' || coalesce(email, '') || ' ' || coalesce(phone, ''))
+);
+CREATE INDEX deliveries_search_trgm_idx ON deliveries USING GIN (
+    (coalesce(name, '') || ' ' || coalesce(city, '') || ' ' || coalesce(address, '') || ' ' || coalesce(email, '') || ' ' || coalesce(phone, '')) gin_trgm_ops
+);
+
+CREATE INDEX items_name_fts_idx ON items USING GIN (to_tsvector('simple', name));
+CREATE INDEX items_name_trgm_idx ON items USING GIN (name gin_trgm_ops);
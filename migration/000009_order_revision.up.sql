@@ -0,0 +1,3 @@
+-- Ревизия заказа для оптимистичной блокировки (ETag / If-Match),
+-- увеличивается при каждой записи, в отличие от event_version из upstream
+ALTER TABLE orders ADD COLUMN revision BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,8 @@
+-- Версия и время события удалённых заказов, чтобы повторно доставленный
+-- старый upsert не восстановил заказ после удаления
+CREATE TABLE order_tombstones (
+                        order_uid     VARCHAR PRIMARY KEY,
+                        event_version BIGINT NOT NULL DEFAULT 0,
+                        event_time    TIMESTAMP WITH TIME ZONE NOT NULL,
+                        deleted_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
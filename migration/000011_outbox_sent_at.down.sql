@@ -0,0 +1,2 @@
+-- Удаление индекса по времени отправки событий
+DROP INDEX IF EXISTS outbox_sent_at_idx;
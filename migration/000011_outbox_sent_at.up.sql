@@ -0,0 +1,2 @@
+-- Индекс для удаления отправленных событий старше срока хранения
+CREATE INDEX outbox_sent_at_idx ON outbox (sent_at) WHERE sent_at IS NOT NULL;
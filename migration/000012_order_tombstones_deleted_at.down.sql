@@ -0,0 +1,2 @@
+-- Удаление индекса по времени удаления заказов
+DROP INDEX IF EXISTS order_tombstones_deleted_at_idx;
@@ -0,0 +1,2 @@
+-- Индекс для удаления записей об удалённых заказах старше срока хранения
+CREATE INDEX order_tombstones_deleted_at_idx ON order_tombstones (deleted_at);
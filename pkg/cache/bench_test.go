@@ -0,0 +1,106 @@
+package cache_test
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+	"wb-task-L0/pkg/cache"
+	"wb-task-L0/pkg/models"
+)
+
+const benchKeys = 10000
+
+var benchOrders = func() []models.Order {
+	orders := make([]models.Order, benchKeys)
+	for i := range orders {
+		orders[i] = order(strconv.Itoa(i))
+	}
+	return orders
+}()
+
+func newBenchCache(shards int) cache.Cache {
+	c := cache.New(cache.Config{Shards: shards})
+	for _, o := range benchOrders {
+		c.Set(o)
+	}
+	return c
+}
+
+// benchMixed runs parallel Get/Set traffic with writePercent of writes,
+// roughly what the HTTP handlers and the Kafka consumer do together.
+func benchMixed(b *testing.B, shards, writePercent int) {
+	c := newBenchCache(shards)
+	var seq atomic.Uint64
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			n := seq.Add(1)
+			o := benchOrders[n%benchKeys]
+			if int(n%100) < writePercent {
+				c.Set(o)
+			} else {
+				c.Get(o.OrderUID)
+			}
+		}
+	})
+}
+
+func BenchmarkOrderCache_Read90(b *testing.B)   { benchMixed(b, 1, 10) }
+func BenchmarkShardedCache_Read90(b *testing.B) { benchMixed(b, 16, 10) }
+func BenchmarkOrderCache_Read50(b *testing.B)   { benchMixed(b, 1, 50) }
+func BenchmarkShardedCache_Read50(b *testing.B) { benchMixed(b, 16, 50) }
+
+// benchGetAllUnderWrites measures reads while a writer keeps the cache busy
+// and another goroutine copies it with GetAll.
+func benchGetAllUnderWrites(b *testing.B, shards int) {
+	c := newBenchCache(shards)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				c.Set(benchOrders[i%benchKeys])
+			}
+		}
+	}()
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				_ = c.GetAll()
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Get(benchOrders[i%benchKeys].OrderUID)
+			i++
+		}
+	})
+}
+
+func BenchmarkOrderCache_GetUnderWrites(b *testing.B)   { benchGetAllUnderWrites(b, 1) }
+func BenchmarkShardedCache_GetUnderWrites(b *testing.B) { benchGetAllUnderWrites(b, 16) }
+
+// BenchmarkOrderCache_SetMissing inserts into a full negative cache, every
+// insert has to make room.
+func BenchmarkOrderCache_SetMissing(b *testing.B) {
+	c := cache.NewCache(cache.Config{NegativeTTL: time.Hour})
+	for i := 0; i < 100000; i++ {
+		c.SetMissing(strconv.Itoa(i))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.SetMissing(strconv.Itoa(i))
+	}
+}
@@ -1,62 +1,395 @@
 package cache
 
 import (
+	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 	"wb-task-L0/pkg/models"
 )
 
+// Cache is what the service, the consumer and the background jobs use.
+// It is implemented by OrderCache and ShardedCache, New picks one from the
+// config.
+type Cache interface {
+	Get(id string) (models.Order, bool)
+	GetStale(id string) (models.Order, bool)
+	Set(order models.Order)
+	SetIfNewer(order models.Order) bool
+	Delete(id string)
+	DeletePrefix(prefix string) int
+	Contains(id string) bool
+	Keys(prefix string) []string
+	GetAll() []models.Order
+	LoadFromDB(orders []models.Order)
+	Len() int
+	Stats() Stats
+
+	GetByTrackNumber(track string) []models.Order
+	GetByCustomer(customerID string) []models.Order
+	GetByTransaction(transaction string) []models.Order
+
+	SetMissing(id string)
+	IsMissing(id string) bool
+
+	StartRefresher(ctx context.Context, load Loader)
+	SaveSnapshot(path string) error
+	LoadSnapshot(path string, maxAge time.Duration) (time.Time, error)
+	StartSnapshots(ctx context.Context, path string, interval time.Duration)
+
+	// setIfAbsent reports false when the order was left out because the
+	// cache is full.
+	setIfAbsent(order models.Order) bool
+	// empty returns a cache of the same kind and config, swap takes over
+	// its contents. Together they let a rebuild run beside the live cache.
+	// Between record and swap the live cache remembers its writes, swap
+	// applies them on top of the fresh contents so none are lost.
+	empty() Cache
+	record()
+	swap(fresh Cache)
+	discard()
+}
+
+// Config bounds the cache. Zero limits mean unbounded, zero TTL means
+// entries never expire.
+type Config struct {
+	MaxEntries int
+	MaxBytes   int64
+	Policy     Policy
+	// Shards > 1 splits the cache into independently locked shards, the
+	// limits are divided between them.
+	Shards int
+
+	TTL time.Duration
+	// RefreshAhead reloads entries that were read since their last load
+	// when they are this close to expiry.
+	RefreshAhead time.Duration
+	// StaleTTL keeps expired entries around this long so they can be
+	// served while the database is unreachable.
+	StaleTTL time.Duration
+	// NegativeTTL remembers ids that were not found in the database, zero
+	// disables negative caching.
+	NegativeTTL time.Duration
+}
+
+type Stats struct {
+	Entries      int     `json:"entries"`
+	Bytes        int64   `json:"bytes"`
+	Hits         uint64  `json:"hits"`
+	Misses       uint64  `json:"misses"`
+	Evictions    uint64  `json:"evictions"`
+	Refreshes    uint64  `json:"refreshes"`
+	Stale        uint64  `json:"stale_served"`
+	Negative     int     `json:"negative_entries"`
+	NegativeHits uint64  `json:"negative_hits"`
+	HitRatio     float64 `json:"hit_ratio"`
+}
+
+type entry struct {
+	order     models.Order
+	size      int64
+	expiresAt time.Time
+	accessed  bool
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
 type OrderCache struct {
-	mu     sync.RWMutex
-	orders map[string]models.Order
+	mu      sync.Mutex
+	cfg     Config
+	orders  map[string]entry
+	missing negativeCache
+	indexes indexes
+	bytes   int64
+	evictor evictor
+	// pending holds the writes made while a rebuild runs, nil marks a
+	// delete. The map itself is nil when no rebuild runs.
+	pending map[string]*models.Order
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+	refreshes atomic.Uint64
+	stale     atomic.Uint64
+	negHits   atomic.Uint64
+}
+
+// New returns a ShardedCache when cfg.Shards > 1 and an OrderCache otherwise.
+func New(cfg Config) Cache {
+	if cfg.Shards > 1 {
+		return NewShardedCache(cfg)
+	}
+	return NewCache(cfg)
 }
 
-func NewCache() *OrderCache {
+func NewCache(cfg Config) *OrderCache {
+	if cfg.Policy == "" {
+		cfg.Policy = PolicyLRU
+	}
 	return &OrderCache{
-		orders: make(map[string]models.Order),
+		cfg:     cfg,
+		orders:  make(map[string]entry),
+		missing: newNegativeCache(),
+		indexes: newIndexes(),
+		evictor: newEvictor(cfg.Policy),
 	}
 }
 
 func (c *OrderCache) Set(order models.Order) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.orders[order.OrderUID] = order
+	c.set(order)
+	if c.pending != nil {
+		o := order.Clone()
+		c.pending[order.OrderUID] = &o
+	}
 }
 
+// SetIfNewer is Set for orders read from the database outside the cache
+// lock. A cached entry with a higher revision was written meanwhile and is
+// kept, storing the read would bring back an older revision.
+func (c *OrderCache) SetIfNewer(order models.Order) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cur, ok := c.orders[order.OrderUID]; ok && olderThan(order, cur.order) {
+		return false
+	}
+	c.set(order)
+	if c.pending != nil {
+		o := order.Clone()
+		c.pending[order.OrderUID] = &o
+	}
+	return true
+}
+
+// setIfAbsent is used by the warm-up, whose pages may be older than what
+// concurrent writers already put into the cache. It never evicts: pages
+// come newest first, making room would push out the newer orders loaded
+// before.
+func (c *OrderCache) setIfAbsent(order models.Order) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.orders[order.OrderUID]; ok {
+		return true
+	}
+	if !c.fits(EstimateSize(&order)) {
+		return false
+	}
+	c.set(order)
+	return true
+}
+
+// fits reports whether one more order of the given size stays within the
+// limits.
+func (c *OrderCache) fits(size int64) bool {
+	return (c.cfg.MaxEntries <= 0 || len(c.orders) < c.cfg.MaxEntries) &&
+		(c.cfg.MaxBytes <= 0 || c.bytes+size <= c.cfg.MaxBytes)
+}
+
+// set stores a copy of the order and evicts until the cache fits its limits
+// again. An order larger than the whole byte budget is not cached at all.
+//
+// Entries are never shared with callers: set copies on the way in and every
+// read copies on the way out, so neither side can mutate the other's Items.
+func (c *OrderCache) set(order models.Order) {
+	order = order.Clone()
+	c.missing.remove(order.OrderUID)
+	size := EstimateSize(&order)
+	if c.cfg.MaxBytes > 0 && size > c.cfg.MaxBytes {
+		c.remove(order.OrderUID)
+		return
+	}
+
+	if old, ok := c.orders[order.OrderUID]; ok {
+		c.bytes -= old.size
+		c.indexes.remove(&old.order)
+	}
+	e := entry{order: order, size: size}
+	if c.cfg.TTL > 0 {
+		e.expiresAt = time.Now().Add(c.cfg.TTL)
+	}
+	c.orders[order.OrderUID] = e
+	c.indexes.add(&order)
+	c.bytes += size
+	c.evictor.add(order.OrderUID)
+
+	for c.overLimit() {
+		key, ok := c.evictor.victim(order.OrderUID)
+		if !ok {
+			return
+		}
+		c.remove(key)
+		c.evictions.Add(1)
+	}
+}
+
+func (c *OrderCache) overLimit() bool {
+	return c.cfg.MaxEntries > 0 && len(c.orders) > c.cfg.MaxEntries ||
+		c.cfg.MaxBytes > 0 && c.bytes > c.cfg.MaxBytes
+}
+
+// Get returns a fresh entry. Expired entries are reported as misses, but
+// may still be read with GetStale.
 func (c *OrderCache) Get(id string) (models.Order, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	order, ok := c.orders[id]
-	return order, ok
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.orders[id]
+	if !ok || e.expired(time.Now()) {
+		c.misses.Add(1)
+		return models.Order{}, false
+	}
+	c.hits.Add(1)
+	c.evictor.access(id)
+	if !e.accessed {
+		e.accessed = true
+		c.orders[id] = e
+	}
+	return e.order.Clone(), true
+}
+
+// GetStale returns an entry even if it has expired, as long as it is still
+// within StaleTTL. It is meant for serving reads while the database is down.
+func (c *OrderCache) GetStale(id string) (models.Order, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.orders[id]
+	if !ok || c.pastStale(&e, time.Now()) {
+		return models.Order{}, false
+	}
+	c.stale.Add(1)
+	return e.order.Clone(), true
+}
+
+// pastStale reports whether the entry is past its stale window, i.e. may
+// no longer be served at all. The refresher purges such entries.
+func (c *OrderCache) pastStale(e *entry, now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt.Add(c.cfg.StaleTTL))
 }
 
 func (c *OrderCache) Delete(id string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	delete(c.orders, id)
+	c.remove(id)
+	if c.pending != nil {
+		c.pending[id] = nil
+	}
+}
+
+func (c *OrderCache) remove(id string) {
+	if e, ok := c.orders[id]; ok {
+		c.bytes -= e.size
+		c.indexes.remove(&e.order)
+		delete(c.orders, id)
+		c.evictor.remove(id)
+	}
 }
 
 func (c *OrderCache) GetAll() []models.Order {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	orders := make([]models.Order, 0, len(c.orders))
-	for _, order := range c.orders {
-		orders = append(orders, order)
+	for _, e := range c.orders {
+		orders = append(orders, e.order.Clone())
 	}
 	return orders
 }
 
+// LoadFromDB replaces the cache contents. With limits configured only the
+// orders that fit are kept, later ones in the slice win.
 func (c *OrderCache) LoadFromDB(orders []models.Order) {
-	newMap := make(map[string]models.Order, len(orders))
+	fresh := NewCache(c.cfg)
 	for _, o := range orders {
-		newMap[o.OrderUID] = o
+		fresh.set(o)
+	}
+	c.replaceWith(fresh)
+}
+
+func (c *OrderCache) empty() Cache {
+	return NewCache(c.cfg)
+}
+
+func (c *OrderCache) record() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = make(map[string]*models.Order)
+}
+
+func (c *OrderCache) discard() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = nil
+}
+
+// swap takes over the entries of fresh and replays the writes recorded
+// since record under the same lock, so no Set or Delete made during the
+// rebuild is lost. A recorded order older than the loaded one is skipped.
+func (c *OrderCache) swap(fresh Cache) {
+	f := fresh.(*OrderCache)
+	f.mu.Lock()
+	orders, ix, bytes, ev := f.orders, f.indexes, f.bytes, f.evictor
+	f.mu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.orders, c.indexes, c.bytes, c.evictor = orders, ix, bytes, ev
+
+	pending := c.pending
+	c.pending = nil
+	for id, order := range pending {
+		if order == nil {
+			c.remove(id)
+			continue
+		}
+		if cur, ok := c.orders[id]; !ok || !olderThan(*order, cur.order) {
+			c.set(*order)
+		}
 	}
+}
+
+// olderThan reports whether order is an earlier revision than cur. Every
+// write bumps the revision, so a lower one can only come from a read that
+// raced with a newer write.
+func olderThan(order, cur models.Order) bool {
+	return order.Revision < cur.Revision
+}
+
+// replaceWith takes over the entries of fresh, keeping the counters.
+func (c *OrderCache) replaceWith(fresh *OrderCache) {
+	fresh.mu.Lock()
+	orders, ix, bytes, ev := fresh.orders, fresh.indexes, fresh.bytes, fresh.evictor
+	fresh.mu.Unlock()
+
 	c.mu.Lock()
-	c.orders = newMap
+	c.orders, c.indexes, c.bytes, c.evictor = orders, ix, bytes, ev
 	c.mu.Unlock()
 }
 
 func (c *OrderCache) Len() int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return len(c.orders)
 }
+
+func (c *OrderCache) Stats() Stats {
+	c.mu.Lock()
+	entries, bytes, negative := len(c.orders), c.bytes, len(c.missing.expires)
+	c.mu.Unlock()
+
+	s := Stats{
+		Entries:      entries,
+		Bytes:        bytes,
+		Negative:     negative,
+		NegativeHits: c.negHits.Load(),
+		Hits:         c.hits.Load(),
+		Misses:       c.misses.Load(),
+		Evictions:    c.evictions.Load(),
+		Refreshes:    c.refreshes.Load(),
+		Stale:        c.stale.Load(),
+	}
+	if total := s.Hits + s.Misses; total > 0 {
+		s.HitRatio = float64(s.Hits) / float64(total)
+	}
+	return s
+}
@@ -0,0 +1,582 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"wb-task-L0/pkg/cache"
+	"wb-task-L0/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func order(uid string) models.Order {
+	return models.Order{
+		OrderUID:    uid,
+		TrackNumber: "track-" + uid,
+		CustomerID:  "cust-" + uid,
+		Payment:     models.Payment{Transaction: "tx-" + uid},
+		Items:       []models.Item{{ItemID: "it-" + uid, Name: "item"}},
+	}
+}
+
+func TestOrderCache_LRU(t *testing.T) {
+	c := cache.NewCache(cache.Config{MaxEntries: 2, Policy: cache.PolicyLRU})
+
+	c.Set(order("a"))
+	c.Set(order("b"))
+	_, _ = c.Get("a")
+	c.Set(order("c"))
+
+	_, okA := c.Get("a")
+	_, okB := c.Get("b")
+	_, okC := c.Get("c")
+	assert.True(t, okA)
+	assert.False(t, okB, "b is the least recently used")
+	assert.True(t, okC)
+	assert.Equal(t, uint64(1), c.Stats().Evictions)
+}
+
+func TestOrderCache_LFU(t *testing.T) {
+	c := cache.NewCache(cache.Config{MaxEntries: 2, Policy: cache.PolicyLFU})
+
+	c.Set(order("a"))
+	c.Set(order("b"))
+	for i := 0; i < 3; i++ {
+		_, _ = c.Get("b")
+	}
+	_, _ = c.Get("a")
+	c.Set(order("c"))
+
+	_, okA := c.Get("a")
+	_, okB := c.Get("b")
+	assert.False(t, okA, "a is the least frequently used")
+	assert.True(t, okB)
+}
+
+func TestOrderCache_MaxBytes(t *testing.T) {
+	o := order("a")
+	size := cache.EstimateSize(&o)
+	c := cache.NewCache(cache.Config{MaxBytes: size*2 + size/2, NegativeTTL: time.Minute})
+
+	c.Set(order("a"))
+	c.Set(order("b"))
+	c.Set(order("c"))
+	assert.Equal(t, 2, c.Len())
+	assert.LessOrEqual(t, c.Stats().Bytes, size*2+size/2)
+
+	huge := order("huge")
+	huge.Delivery.Address = strings.Repeat("x", int(size*3))
+	c.SetMissing("huge")
+	c.Set(huge)
+	_, ok := c.Get("huge")
+	assert.False(t, ok, "orders larger than the budget are not cached")
+	assert.False(t, c.IsMissing("huge"), "but they exist, so they are no longer reported missing")
+}
+
+func TestOrderCache_Negative(t *testing.T) {
+	c := cache.NewCache(cache.Config{NegativeTTL: 20 * time.Millisecond})
+	c.SetMissing("gone")
+	assert.True(t, c.IsMissing("gone"))
+
+	c.Set(order("gone"))
+	assert.False(t, c.IsMissing("gone"), "a created order clears its mark")
+
+	c.SetMissing("a")
+	time.Sleep(30 * time.Millisecond)
+	c.SetMissing("b")
+	assert.False(t, c.IsMissing("a"))
+	assert.True(t, c.IsMissing("b"))
+	assert.Equal(t, 1, c.Stats().Negative, "expired marks are dropped on insert")
+}
+
+func TestOrderCache_NegativeBound(t *testing.T) {
+	const limit = 100000 // maxNegativeEntries
+
+	c := cache.NewCache(cache.Config{NegativeTTL: time.Hour})
+	c.SetMissing("first")
+	c.SetMissing("first") // set again, its older record must not drop it
+	for i := 0; i < limit; i++ {
+		c.SetMissing(strconv.Itoa(i))
+	}
+
+	assert.Equal(t, limit, c.Stats().Negative)
+	assert.False(t, c.IsMissing("first"), "the oldest mark makes room")
+	assert.True(t, c.IsMissing("0"))
+	assert.True(t, c.IsMissing(strconv.Itoa(limit-1)))
+}
+
+func TestOrderCache_Stats(t *testing.T) {
+	c := cache.NewCache(cache.Config{})
+	c.LoadFromDB([]models.Order{order("a"), order("b")})
+
+	_, _ = c.Get("a")
+	_, _ = c.Get("missing")
+
+	s := c.Stats()
+	assert.Equal(t, 2, s.Entries)
+	assert.Equal(t, uint64(1), s.Hits)
+	assert.Equal(t, uint64(1), s.Misses)
+	assert.InDelta(t, 0.5, s.HitRatio, 1e-9)
+}
+
+func TestOrderCache_TTL(t *testing.T) {
+	c := cache.NewCache(cache.Config{TTL: 20 * time.Millisecond, StaleTTL: time.Minute})
+	c.Set(order("a"))
+
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	time.Sleep(30 * time.Millisecond)
+	_, ok = c.Get("a")
+	assert.False(t, ok, "expired entries are misses")
+
+	stale, ok := c.GetStale("a")
+	assert.True(t, ok)
+	assert.Equal(t, "a", stale.OrderUID)
+}
+
+func TestOrderCache_GetStale_Window(t *testing.T) {
+	c := cache.NewCache(cache.Config{TTL: 10 * time.Millisecond, StaleTTL: 20 * time.Millisecond})
+	c.Set(order("a"))
+
+	time.Sleep(50 * time.Millisecond)
+	_, ok := c.GetStale("a")
+	assert.False(t, ok, "entries past their stale window are not served")
+}
+
+func TestOrderCache_RefreshKeepsNewerWrites(t *testing.T) {
+	c := cache.NewCache(cache.Config{TTL: 1500 * time.Millisecond, RefreshAhead: time.Second})
+	for _, id := range []string{"updated", "deleted", "hot"} {
+		o := order(id)
+		o.Revision = 1
+		c.Set(o)
+		_, _ = c.Get(id)
+	}
+
+	var loads atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.StartRefresher(ctx, func(id string) (models.Order, error) {
+		defer loads.Add(1)
+		// writes that land while the load is in flight
+		switch id {
+		case "updated":
+			newer := order(id)
+			newer.Revision, newer.TrackNumber = 3, "consumer"
+			c.Set(newer)
+		case "deleted":
+			c.Delete(id)
+		}
+		o := order(id)
+		o.Revision, o.TrackNumber = 2, "refreshed"
+		return o, nil
+	})
+
+	require.Eventually(t, func() bool {
+		return loads.Load() >= 3 && c.Stats().Refreshes == 1
+	}, 3*time.Second, 50*time.Millisecond)
+
+	got, _ := c.Get("hot")
+	assert.Equal(t, "refreshed", got.TrackNumber)
+	got, _ = c.Get("updated")
+	assert.Equal(t, "consumer", got.TrackNumber, "a refresh does not replace a newer revision")
+	assert.False(t, c.Contains("deleted"), "a refresh does not bring back a deleted order")
+}
+
+func TestOrderCache_SetIfNewer(t *testing.T) {
+	for name, c := range map[string]cache.Cache{
+		"plain":   cache.NewCache(cache.Config{}),
+		"sharded": cache.NewShardedCache(cache.Config{Shards: 4}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			older, newer := order("a"), order("a")
+			older.Revision, newer.Revision = 1, 2
+
+			assert.True(t, c.SetIfNewer(newer))
+			assert.False(t, c.SetIfNewer(older))
+			assert.True(t, c.SetIfNewer(newer), "the same revision is reloaded")
+			got, _ := c.Get("a")
+			assert.Equal(t, int64(2), got.Revision)
+		})
+	}
+}
+
+func TestOrderCache_RefreshAhead(t *testing.T) {
+	c := cache.NewCache(cache.Config{TTL: 1500 * time.Millisecond, RefreshAhead: time.Second})
+	c.Set(order("hot"))
+	c.Set(order("cold"))
+	_, _ = c.Get("hot")
+
+	var loaded sync.Map
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.StartRefresher(ctx, func(id string) (models.Order, error) {
+		loaded.Store(id, true)
+		o := order(id)
+		o.TrackNumber = "refreshed"
+		return o, nil
+	})
+
+	require.Eventually(t, func() bool {
+		return c.Stats().Refreshes == 1
+	}, 3*time.Second, 50*time.Millisecond)
+
+	_, coldLoaded := loaded.Load("cold")
+	assert.False(t, coldLoaded, "entries nobody read are left to expire")
+
+	got, ok := c.Get("hot")
+	require.True(t, ok)
+	assert.Equal(t, "refreshed", got.TrackNumber)
+}
+
+func TestWarmer_Run(t *testing.T) {
+	all := []models.Order{order("e"), order("d"), order("c"), order("b"), order("a")}
+
+	var afters []string
+	load := func(_ context.Context, after *models.Order, _ time.Time, limit int) ([]models.Order, error) {
+		start := 0
+		if after != nil {
+			afters = append(afters, after.OrderUID)
+			for i, o := range all {
+				if o.OrderUID == after.OrderUID {
+					start = i + 1
+				}
+			}
+		}
+		end := min(start+limit, len(all))
+		return all[start:end], nil
+	}
+	count := func(context.Context, time.Time) (int64, error) { return int64(len(all)), nil }
+
+	c := cache.NewCache(cache.Config{})
+	c.Set(models.Order{OrderUID: "d", TrackNumber: "newer"})
+
+	w := cache.NewWarmer(c, cache.WarmupConfig{BatchSize: 2, MaxOrders: 3}, load, count)
+	assert.Equal(t, cache.WarmupPending, w.Progress().State)
+	require.NoError(t, w.Run(context.Background()))
+
+	assert.Equal(t, []string{"d"}, afters, "second page continues after the last order of the first")
+	assert.Equal(t, 3, c.Len(), "only the most recent MaxOrders are loaded")
+	got, _ := c.Get("d")
+	assert.Equal(t, "newer", got.TrackNumber, "warm-up does not overwrite fresher entries")
+
+	p := w.Progress()
+	assert.Equal(t, cache.WarmupDone, p.State)
+	assert.Equal(t, 3, p.Loaded)
+	assert.Equal(t, int64(3), p.Total)
+	assert.Equal(t, 2, p.Batches)
+	assert.Equal(t, float64(100), p.Percent)
+}
+
+func TestWarmer_StopsWhenFull(t *testing.T) {
+	all := []models.Order{order("newest"), order("mid"), order("older"), order("oldest")}
+	var pages int
+	load := func(_ context.Context, after *models.Order, _ time.Time, limit int) ([]models.Order, error) {
+		pages++
+		start := 0
+		if after != nil {
+			start = slices.IndexFunc(all, func(o models.Order) bool { return o.OrderUID == after.OrderUID }) + 1
+		}
+		return all[start:min(start+limit, len(all))], nil
+	}
+
+	c := cache.NewCache(cache.Config{MaxEntries: 2})
+	w := cache.NewWarmer(c, cache.WarmupConfig{BatchSize: 1}, load, nil)
+	require.NoError(t, w.Run(context.Background()))
+
+	assert.ElementsMatch(t, []string{"newest", "mid"}, c.Keys(""), "the newest orders are kept")
+	assert.Equal(t, 3, pages, "loading stops at the first page that doesn't fit")
+	assert.Zero(t, c.Stats().Evictions)
+}
+
+func TestWarmer_RebuildKeepsConcurrentWrites(t *testing.T) {
+	for name, c := range map[string]cache.Cache{
+		"plain":   cache.NewCache(cache.Config{}),
+		"sharded": cache.NewShardedCache(cache.Config{Shards: 4}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			c.Set(order("deleted"))
+			c.Set(order("updated"))
+
+			// the page is read before the writes below reach the cache
+			stored := []models.Order{order("deleted"), order("updated"), order("stale"), order("kept")}
+			stored[1].Revision = 1
+			stored[2].Revision = 3
+			load := func(context.Context, *models.Order, time.Time, int) ([]models.Order, error) {
+				c.Delete("deleted")
+				updated := order("updated")
+				updated.Revision, updated.TrackNumber = 2, "new"
+				c.Set(updated)
+				older := order("stale")
+				older.Revision, older.TrackNumber = 2, "old"
+				c.Set(older)
+				return stored, nil
+			}
+
+			w := cache.NewWarmer(c, cache.WarmupConfig{BatchSize: 10}, load, nil)
+			require.NoError(t, w.Rebuild(context.Background()))
+
+			assert.False(t, c.Contains("deleted"), "a delete during the rebuild is not undone")
+			got, _ := c.Get("updated")
+			assert.Equal(t, "new", got.TrackNumber, "an update during the rebuild is not reverted")
+			got, _ = c.Get("stale")
+			assert.Equal(t, int64(3), got.Revision, "an older write does not replace the loaded revision")
+			assert.True(t, c.Contains("kept"))
+
+			assert.Equal(t, cache.WarmupPending, w.Progress().State, "rebuilds do not touch the startup progress")
+			assert.Equal(t, cache.WarmupDone, w.RebuildProgress().State)
+			assert.Equal(t, 4, w.RebuildProgress().Loaded)
+		})
+	}
+}
+
+func TestWarmer_FailedRebuildKeepsCache(t *testing.T) {
+	c := cache.NewCache(cache.Config{})
+	c.Set(order("a"))
+	load := func(context.Context, *models.Order, time.Time, int) ([]models.Order, error) {
+		return nil, errors.New("db down")
+	}
+
+	w := cache.NewWarmer(c, cache.WarmupConfig{}, load, nil)
+	require.Error(t, w.Rebuild(context.Background()))
+	assert.True(t, c.Contains("a"))
+	assert.Equal(t, cache.WarmupFailed, w.RebuildProgress().State)
+	assert.Equal(t, "db down", w.RebuildProgress().Error)
+}
+
+func TestOrderCache_Snapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.snap")
+
+	src := cache.NewCache(cache.Config{})
+	src.Set(order("a"))
+	src.Set(order("b"))
+	before := time.Now()
+	require.NoError(t, src.SaveSnapshot(path))
+
+	dst := cache.NewCache(cache.Config{})
+	watermark, err := dst.LoadSnapshot(path, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, watermark.Before(before))
+	assert.Equal(t, 2, dst.Len())
+	got, ok := dst.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, order("a"), got)
+
+	_, err = cache.NewCache(cache.Config{}).LoadSnapshot(path, time.Nanosecond)
+	assert.ErrorIs(t, err, cache.ErrSnapshotStale)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)-1] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	empty := cache.NewCache(cache.Config{})
+	_, err = empty.LoadSnapshot(path, time.Hour)
+	assert.ErrorIs(t, err, cache.ErrSnapshotCorrupt)
+	assert.Equal(t, 0, empty.Len(), "a corrupt snapshot must not be applied")
+
+	require.NoError(t, os.WriteFile(path, data[:10], 0o644))
+	_, err = empty.LoadSnapshot(path, time.Hour)
+	assert.ErrorIs(t, err, cache.ErrSnapshotCorrupt)
+}
+
+func TestWarmer_CatchUp(t *testing.T) {
+	c := cache.NewCache(cache.Config{})
+	c.Set(order("kept"))
+	c.Set(order("changed"))
+	c.Set(order("deleted"))
+	c.Set(order("vanished"))
+
+	since := time.Now().Add(-time.Minute)
+	changes := func(_ context.Context, s time.Time) ([]string, []string, error) {
+		assert.Equal(t, since, s)
+		return []string{"changed", "new", "vanished"}, []string{"deleted"}, nil
+	}
+	load := func(id string) (models.Order, error) {
+		if id == "vanished" {
+			return models.Order{}, gorm.ErrRecordNotFound
+		}
+		o := order(id)
+		o.TrackNumber = "reloaded"
+		return o, nil
+	}
+
+	w := cache.NewWarmer(c, cache.WarmupConfig{}, nil, nil)
+	require.NoError(t, w.CatchUp(context.Background(), since, changes, load))
+
+	for id, want := range map[string]string{"kept": "track-kept", "changed": "reloaded", "new": "reloaded"} {
+		got, ok := c.Get(id)
+		require.True(t, ok, id)
+		assert.Equal(t, want, got.TrackNumber, id)
+	}
+	_, ok := c.Get("deleted")
+	assert.False(t, ok)
+	_, ok = c.Get("vanished")
+	assert.False(t, ok)
+
+	p := w.Progress()
+	assert.Equal(t, cache.WarmupDone, p.State)
+	assert.Equal(t, int64(4), p.Total)
+}
+
+func TestOrderCache_SecondaryIndexes(t *testing.T) {
+	c := cache.NewCache(cache.Config{MaxEntries: 3})
+
+	a, b := order("a"), order("b")
+	b.CustomerID = a.CustomerID
+	c.Set(a)
+	c.Set(b)
+
+	assert.Len(t, c.GetByCustomer(a.CustomerID), 2)
+	assert.Len(t, c.GetByTrackNumber("track-a"), 1)
+	assert.Len(t, c.GetByTransaction("tx-b"), 1)
+
+	moved := a
+	moved.TrackNumber = "track-moved"
+	c.Set(moved)
+	assert.Empty(t, c.GetByTrackNumber("track-a"), "replaced orders leave their old keys")
+	assert.Len(t, c.GetByTrackNumber("track-moved"), 1)
+
+	c.Delete("b")
+	assert.Len(t, c.GetByCustomer(a.CustomerID), 1)
+	assert.Empty(t, c.GetByTransaction("tx-b"))
+
+	c.Set(order("c"))
+	c.Set(order("d"))
+	c.Set(order("e"))
+	assert.Empty(t, c.GetByCustomer(a.CustomerID), "evicted orders leave the indexes")
+
+	c.LoadFromDB([]models.Order{order("x")})
+	assert.Empty(t, c.GetByTrackNumber("track-e"))
+	assert.Len(t, c.GetByTransaction("tx-x"), 1)
+}
+
+func TestShardedCache(t *testing.T) {
+	c := cache.New(cache.Config{Shards: 4, MaxEntries: 40})
+	require.IsType(t, &cache.ShardedCache{}, c)
+
+	for i := 0; i < 100; i++ {
+		c.Set(order(strconv.Itoa(i)))
+	}
+	assert.LessOrEqual(t, c.Len(), 40, "limits are shared out between shards")
+	assert.Len(t, c.GetAll(), c.Len())
+
+	c.LoadFromDB([]models.Order{order("a"), order("b")})
+	assert.Equal(t, 2, c.Len())
+	got, ok := c.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, "track-a", got.TrackNumber)
+	assert.Len(t, c.GetByTransaction("tx-b"), 1)
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Empty(t, c.GetByTrackNumber("track-a"))
+
+	st := c.Stats()
+	assert.Equal(t, 1, st.Entries)
+	assert.Equal(t, uint64(2), st.Hits)
+	assert.Equal(t, uint64(2), st.Misses)
+}
+
+func TestOrderCache_NoAliasing(t *testing.T) {
+	c := cache.NewCache(cache.Config{})
+
+	o := order("a")
+	c.Set(o)
+	o.Items[0].Name = "changed by writer"
+
+	got, ok := c.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, "item", got.Items[0].Name, "Set keeps its own copy")
+
+	got.Items[0].Name = "changed by reader"
+	got.Items = append(got.Items, models.Item{ItemID: "extra"})
+	again, _ := c.Get("a")
+	assert.Equal(t, []models.Item{{ItemID: "it-a", Name: "item"}}, again.Items, "Get hands out copies")
+}
+
+// TestOrderCache_ConcurrentCopies is meant for -race: readers scribble over
+// what they get and writers over what they set, and every order read must
+// still be one that was written as a whole.
+func TestOrderCache_ConcurrentCopies(t *testing.T) {
+	for _, shards := range []int{1, 4} {
+		c := cache.New(cache.Config{Shards: shards})
+		c.Set(versioned("a", 0))
+
+		var wg sync.WaitGroup
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < 500; i++ {
+					o := versioned("a", w*1000+i)
+					c.Set(o)
+					for j := range o.Items {
+						o.Items[j].Name = "torn"
+					}
+				}
+			}(w)
+		}
+		for r := 0; r < 4; r++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 500; i++ {
+					got, ok := c.Get("a")
+					if !assert.True(t, ok) {
+						return
+					}
+					for _, it := range got.Items {
+						if !assert.Equal(t, got.TrackNumber, it.Name, "items must match their order") {
+							return
+						}
+					}
+					for j := range got.Items {
+						got.Items[j].Name = "torn"
+					}
+					for _, o := range c.GetByCustomer("cust-a") {
+						o.Items[0].Name = "torn"
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func versioned(uid string, v int) models.Order {
+	o := order(uid)
+	o.TrackNumber = "v" + strconv.Itoa(v)
+	o.Items = []models.Item{{ItemID: "1", Name: o.TrackNumber}, {ItemID: "2", Name: o.TrackNumber}}
+	return o
+}
+
+func TestCache_KeysAndDeletePrefix(t *testing.T) {
+	for _, shards := range []int{1, 4} {
+		c := cache.New(cache.Config{Shards: shards})
+		for _, id := range []string{"b2", "a1", "b1", "c1"} {
+			c.Set(order(id))
+		}
+
+		assert.Equal(t, []string{"a1", "b1", "b2", "c1"}, c.Keys(""))
+		assert.Equal(t, []string{"b1", "b2"}, c.Keys("b"))
+
+		assert.Equal(t, 2, c.DeletePrefix("b"))
+		assert.Equal(t, []string{"a1", "c1"}, c.Keys(""))
+		assert.Empty(t, c.GetByTrackNumber("track-b1"))
+		assert.True(t, c.Contains("a1"))
+		assert.False(t, c.Contains("b1"))
+	}
+}
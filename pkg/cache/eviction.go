@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+type Policy string
+
+const (
+	PolicyLRU Policy = "lru"
+	PolicyLFU Policy = "lfu"
+)
+
+// evictor tracks key usage and picks the next key to evict. victim never
+// returns keep, so a freshly inserted key is not evicted right away.
+type evictor interface {
+	add(key string)
+	access(key string)
+	remove(key string)
+	victim(keep string) (string, bool)
+}
+
+func newEvictor(p Policy) evictor {
+	if p == PolicyLFU {
+		return newLFU()
+	}
+	return newLRU()
+}
+
+type lru struct {
+	order *list.List
+	elems map[string]*list.Element
+}
+
+func newLRU() *lru {
+	return &lru{order: list.New(), elems: make(map[string]*list.Element)}
+}
+
+func (l *lru) add(key string) {
+	if e, ok := l.elems[key]; ok {
+		l.order.MoveToFront(e)
+		return
+	}
+	l.elems[key] = l.order.PushFront(key)
+}
+
+func (l *lru) access(key string) {
+	if e, ok := l.elems[key]; ok {
+		l.order.MoveToFront(e)
+	}
+}
+
+func (l *lru) remove(key string) {
+	if e, ok := l.elems[key]; ok {
+		l.order.Remove(e)
+		delete(l.elems, key)
+	}
+}
+
+func (l *lru) victim(keep string) (string, bool) {
+	for e := l.order.Back(); e != nil; e = e.Prev() {
+		if key := e.Value.(string); key != keep {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// lfu evicts the least frequently used key, the least recently used one
+// among equal frequencies.
+type lfu struct {
+	heap  lfuHeap
+	items map[string]*lfuItem
+	tick  uint64
+}
+
+type lfuItem struct {
+	key   string
+	freq  uint64
+	tick  uint64
+	index int
+}
+
+func newLFU() *lfu {
+	return &lfu{items: make(map[string]*lfuItem)}
+}
+
+func (l *lfu) add(key string) {
+	if _, ok := l.items[key]; ok {
+		l.access(key)
+		return
+	}
+	l.tick++
+	it := &lfuItem{key: key, freq: 1, tick: l.tick}
+	l.items[key] = it
+	heap.Push(&l.heap, it)
+}
+
+func (l *lfu) access(key string) {
+	if it, ok := l.items[key]; ok {
+		l.tick++
+		it.freq++
+		it.tick = l.tick
+		heap.Fix(&l.heap, it.index)
+	}
+}
+
+func (l *lfu) remove(key string) {
+	if it, ok := l.items[key]; ok {
+		heap.Remove(&l.heap, it.index)
+		delete(l.items, key)
+	}
+}
+
+func (l *lfu) victim(keep string) (string, bool) {
+	if len(l.heap) == 0 {
+		return "", false
+	}
+	if l.heap[0].key != keep {
+		return l.heap[0].key, true
+	}
+	// the root is kept, the next candidate is its smaller child
+	best := -1
+	for _, i := range []int{1, 2} {
+		if i < len(l.heap) && (best < 0 || l.heap.Less(i, best)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	return l.heap[best].key, true
+}
+
+type lfuHeap []*lfuItem
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x any) {
+	it := x.(*lfuItem)
+	it.index = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *lfuHeap) Pop() any {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return it
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"time"
+	"wb-task-L0/pkg/models"
+)
+
+// index maps a secondary key to the order_uids carrying it. Several orders
+// may share a key, a customer usually has many.
+type index map[string]map[string]struct{}
+
+func (ix index) add(key, id string) {
+	if key == "" {
+		return
+	}
+	ids, ok := ix[key]
+	if !ok {
+		ids = make(map[string]struct{}, 1)
+		ix[key] = ids
+	}
+	ids[id] = struct{}{}
+}
+
+func (ix index) remove(key, id string) {
+	ids, ok := ix[key]
+	if !ok {
+		return
+	}
+	delete(ids, id)
+	if len(ids) == 0 {
+		delete(ix, key)
+	}
+}
+
+// indexes are kept in step with orders under OrderCache.mu.
+type indexes struct {
+	byTrack       index
+	byCustomer    index
+	byTransaction index
+}
+
+func newIndexes() indexes {
+	return indexes{
+		byTrack:       make(index),
+		byCustomer:    make(index),
+		byTransaction: make(index),
+	}
+}
+
+func (ix indexes) add(o *models.Order) {
+	ix.byTrack.add(o.TrackNumber, o.OrderUID)
+	ix.byCustomer.add(o.CustomerID, o.OrderUID)
+	ix.byTransaction.add(o.Payment.Transaction, o.OrderUID)
+}
+
+func (ix indexes) remove(o *models.Order) {
+	ix.byTrack.remove(o.TrackNumber, o.OrderUID)
+	ix.byCustomer.remove(o.CustomerID, o.OrderUID)
+	ix.byTransaction.remove(o.Payment.Transaction, o.OrderUID)
+}
+
+// GetByTrackNumber returns the fresh cached orders with this track number.
+func (c *OrderCache) GetByTrackNumber(track string) []models.Order {
+	return c.lookup(func(ix indexes) index { return ix.byTrack }, track)
+}
+
+// GetByCustomer returns the fresh cached orders of a customer. The cache
+// may hold only some of them, callers needing all must ask the database.
+func (c *OrderCache) GetByCustomer(customerID string) []models.Order {
+	return c.lookup(func(ix indexes) index { return ix.byCustomer }, customerID)
+}
+
+// GetByTransaction returns the fresh cached orders paid by this transaction.
+func (c *OrderCache) GetByTransaction(transaction string) []models.Order {
+	return c.lookup(func(ix indexes) index { return ix.byTransaction }, transaction)
+}
+
+func (c *OrderCache) lookup(pick func(indexes) index, key string) []models.Order {
+	orders := c.collect(pick, key)
+	if len(orders) == 0 {
+		c.misses.Add(1)
+	} else {
+		c.hits.Add(1)
+	}
+	return orders
+}
+
+// collect returns the fresh orders under key without touching the hit
+// counters, ShardedCache counts a lookup once across all shards.
+func (c *OrderCache) collect(pick func(indexes) index, key string) []models.Order {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	var orders []models.Order
+	for id := range pick(c.indexes)[key] {
+		e := c.orders[id]
+		if e.expired(now) {
+			continue
+		}
+		c.evictor.access(id)
+		if !e.accessed {
+			e.accessed = true
+			c.orders[id] = e
+		}
+		orders = append(orders, e.order.Clone())
+	}
+	return orders
+}
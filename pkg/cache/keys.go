@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"slices"
+	"strings"
+)
+
+// Keys returns the cached order_uids starting with prefix, sorted so that
+// callers can page through them.
+func (c *OrderCache) Keys(prefix string) []string {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.orders))
+	for id := range c.orders {
+		if strings.HasPrefix(id, prefix) {
+			keys = append(keys, id)
+		}
+	}
+	c.mu.Unlock()
+
+	slices.Sort(keys)
+	return keys
+}
+
+// Contains reports whether id is cached, fresh or not, without counting as
+// a read.
+func (c *OrderCache) Contains(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.orders[id]
+	return ok
+}
+
+// DeletePrefix evicts every order whose order_uid starts with prefix and
+// returns how many were removed.
+func (c *OrderCache) DeletePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for id := range c.orders {
+		if strings.HasPrefix(id, prefix) {
+			c.remove(id)
+			if c.pending != nil {
+				c.pending[id] = nil
+			}
+			n++
+		}
+	}
+	return n
+}
+
+func (c *ShardedCache) Keys(prefix string) []string {
+	var keys []string
+	for _, s := range c.shards {
+		keys = append(keys, s.Keys(prefix)...)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (c *ShardedCache) Contains(id string) bool { return c.shard(id).Contains(id) }
+
+func (c *ShardedCache) DeletePrefix(prefix string) int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.DeletePrefix(prefix)
+	}
+	return n
+}
@@ -0,0 +1,87 @@
+package cache
+
+import "time"
+
+// maxNegativeEntries bounds the negative cache, a scan over random ids
+// must not grow it without limit.
+const maxNegativeEntries = 100000
+
+// negativeCache maps ids to the time their mark expires. Every mark lives
+// NegativeTTL, so marks expire in the order they were set and a FIFO queue
+// finds the expired and the oldest ones without scanning the map.
+//
+// The queue may hold records of marks that were set again or removed since,
+// they are recognised by their expiry no longer matching the map. The map
+// never holds more ids than the queue has records.
+type negativeCache struct {
+	expires map[string]time.Time
+	queue   []negativeRecord
+	head    int
+}
+
+type negativeRecord struct {
+	id      string
+	expires time.Time
+}
+
+func newNegativeCache() negativeCache {
+	return negativeCache{expires: make(map[string]time.Time)}
+}
+
+func (n *negativeCache) add(id string, now time.Time, ttl time.Duration) {
+	for n.len() > 0 && (now.After(n.queue[n.head].expires) || n.len() >= maxNegativeEntries) {
+		n.pop()
+	}
+	exp := now.Add(ttl)
+	n.expires[id] = exp
+	n.queue = append(n.queue, negativeRecord{id: id, expires: exp})
+}
+
+// pop drops the oldest record and its mark, unless the mark was set again.
+func (n *negativeCache) pop() {
+	rec := n.queue[n.head]
+	if exp, ok := n.expires[rec.id]; ok && exp.Equal(rec.expires) {
+		delete(n.expires, rec.id)
+	}
+	n.queue[n.head] = negativeRecord{}
+	n.head++
+	// reuse the backing array once most of it is consumed
+	if n.head > len(n.queue)/2 {
+		n.queue = n.queue[:copy(n.queue, n.queue[n.head:])]
+		n.head = 0
+	}
+}
+
+// len is the number of queued records, an upper bound of the marks.
+func (n *negativeCache) len() int { return len(n.queue) - n.head }
+
+func (n *negativeCache) remove(id string) { delete(n.expires, id) }
+
+// SetMissing remembers for NegativeTTL that id does not exist. Set clears
+// the mark, so an order created meanwhile is visible right away. When the
+// negative cache is full the oldest mark makes room.
+func (c *OrderCache) SetMissing(id string) {
+	if c.cfg.NegativeTTL <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.missing.add(id, time.Now(), c.cfg.NegativeTTL)
+}
+
+// IsMissing reports whether id was recently looked up and not found.
+func (c *OrderCache) IsMissing(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	exp, ok := c.missing.expires[id]
+	if !ok {
+		return false
+	}
+	if time.Now().After(exp) {
+		c.missing.remove(id)
+		return false
+	}
+	c.negHits.Add(1)
+	return true
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+	"wb-task-L0/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+const minRefreshInterval = time.Second
+
+// Loader fetches the current version of an order, typically
+// OrderRepo.GetByID.
+type Loader func(id string) (models.Order, error)
+
+// StartRefresher periodically reloads hot entries shortly before they
+// expire and drops entries that stayed expired longer than StaleTTL. Loads
+// happen outside the lock, so readers are never blocked by the database.
+// It does nothing when the cache has no TTL.
+func (c *OrderCache) StartRefresher(ctx context.Context, load Loader) {
+	runRefresher(ctx, c.cfg, func() { c.refresh(ctx, load) })
+}
+
+func runRefresher(ctx context.Context, cfg Config, refresh func()) {
+	if cfg.TTL <= 0 {
+		return
+	}
+
+	interval := cfg.RefreshAhead / 2
+	if interval < minRefreshInterval {
+		interval = minRefreshInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refresh()
+		}
+	}
+}
+
+func (c *OrderCache) refresh(ctx context.Context, load Loader) {
+	for _, id := range c.refreshCandidates(time.Now()) {
+		if ctx.Err() != nil {
+			return
+		}
+
+		order, err := load(id)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.Delete(id)
+		case err != nil:
+			// keep the entry, it can still be served stale
+			log.Printf("cache: failed to refresh order %s: %v", id, err)
+		default:
+			if c.replace(order) {
+				c.refreshes.Add(1)
+			}
+		}
+	}
+}
+
+// refreshCandidates purges entries past their stale window and returns the
+// keys of entries that were read since their last load and expire within
+// RefreshAhead.
+func (c *OrderCache) refreshCandidates(now time.Time) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var ids []string
+	for id, e := range c.orders {
+		if e.expiresAt.IsZero() {
+			continue
+		}
+		if c.pastStale(&e, now) {
+			c.remove(id)
+			continue
+		}
+		if e.accessed && e.expiresAt.Sub(now) <= c.cfg.RefreshAhead {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// replace stores a reloaded order only if its entry is still there and not
+// newer. The load ran outside the lock, so a consumer may have set a later
+// revision or deleted the order in the meantime.
+func (c *OrderCache) replace(order models.Order) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cur, ok := c.orders[order.OrderUID]
+	if !ok || olderThan(order, cur.order) {
+		return false
+	}
+	c.set(order)
+	return true
+}
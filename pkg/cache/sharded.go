@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+	"wb-task-L0/pkg/models"
+)
+
+// ShardedCache spreads orders over independently locked OrderCache shards by
+// hash of order_uid, so a writer only blocks readers of its own shard.
+// Limits are split evenly, eviction and expiry are per shard. Secondary
+// index lookups and GetAll visit every shard one at a time.
+type ShardedCache struct {
+	cfg    Config
+	shards []*OrderCache
+
+	// index lookups span shards and are counted here
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func NewShardedCache(cfg Config) *ShardedCache {
+	n := max(cfg.Shards, 1)
+
+	shardCfg := cfg
+	if cfg.MaxEntries > 0 {
+		shardCfg.MaxEntries = (cfg.MaxEntries + n - 1) / n
+	}
+	if cfg.MaxBytes > 0 {
+		shardCfg.MaxBytes = (cfg.MaxBytes + int64(n) - 1) / int64(n)
+	}
+
+	shards := make([]*OrderCache, n)
+	for i := range shards {
+		shards[i] = NewCache(shardCfg)
+	}
+	return &ShardedCache{cfg: cfg, shards: shards}
+}
+
+// shard hashes with inline FNV-1a, hash/fnv would allocate on every call.
+func (c *ShardedCache) shard(id string) *OrderCache {
+	h := uint32(2166136261)
+	for i := 0; i < len(id); i++ {
+		h ^= uint32(id[i])
+		h *= 16777619
+	}
+	return c.shards[h%uint32(len(c.shards))]
+}
+
+func (c *ShardedCache) Get(id string) (models.Order, bool) { return c.shard(id).Get(id) }
+
+func (c *ShardedCache) GetStale(id string) (models.Order, bool) { return c.shard(id).GetStale(id) }
+
+func (c *ShardedCache) Set(order models.Order) { c.shard(order.OrderUID).Set(order) }
+
+func (c *ShardedCache) SetIfNewer(order models.Order) bool {
+	return c.shard(order.OrderUID).SetIfNewer(order)
+}
+
+func (c *ShardedCache) setIfAbsent(order models.Order) bool {
+	return c.shard(order.OrderUID).setIfAbsent(order)
+}
+
+func (c *ShardedCache) Delete(id string) { c.shard(id).Delete(id) }
+
+func (c *ShardedCache) SetMissing(id string) { c.shard(id).SetMissing(id) }
+
+func (c *ShardedCache) IsMissing(id string) bool { return c.shard(id).IsMissing(id) }
+
+// GetAll copies one shard at a time, so it never holds more than one lock.
+func (c *ShardedCache) GetAll() []models.Order {
+	orders := make([]models.Order, 0, c.Len())
+	for _, s := range c.shards {
+		orders = append(orders, s.GetAll()...)
+	}
+	return orders
+}
+
+func (c *ShardedCache) LoadFromDB(orders []models.Order) {
+	fresh := NewShardedCache(c.cfg)
+	for _, o := range orders {
+		fresh.shard(o.OrderUID).set(o)
+	}
+	for i, s := range fresh.shards {
+		c.shards[i].replaceWith(s)
+	}
+}
+
+func (c *ShardedCache) empty() Cache {
+	return NewShardedCache(c.cfg)
+}
+
+func (c *ShardedCache) record() {
+	for _, s := range c.shards {
+		s.record()
+	}
+}
+
+func (c *ShardedCache) discard() {
+	for _, s := range c.shards {
+		s.discard()
+	}
+}
+
+// swap works shard by shard, each shard replays its own recorded writes.
+func (c *ShardedCache) swap(fresh Cache) {
+	for i, s := range fresh.(*ShardedCache).shards {
+		c.shards[i].swap(s)
+	}
+}
+
+func (c *ShardedCache) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.Len()
+	}
+	return n
+}
+
+func (c *ShardedCache) Stats() Stats {
+	total := Stats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+	for _, s := range c.shards {
+		st := s.Stats()
+		total.Entries += st.Entries
+		total.Bytes += st.Bytes
+		total.Hits += st.Hits
+		total.Misses += st.Misses
+		total.Evictions += st.Evictions
+		total.Refreshes += st.Refreshes
+		total.Stale += st.Stale
+		total.Negative += st.Negative
+		total.NegativeHits += st.NegativeHits
+	}
+	if n := total.Hits + total.Misses; n > 0 {
+		total.HitRatio = float64(total.Hits) / float64(n)
+	}
+	return total
+}
+
+func (c *ShardedCache) GetByTrackNumber(track string) []models.Order {
+	return c.gather(func(ix indexes) index { return ix.byTrack }, track)
+}
+
+func (c *ShardedCache) GetByCustomer(customerID string) []models.Order {
+	return c.gather(func(ix indexes) index { return ix.byCustomer }, customerID)
+}
+
+func (c *ShardedCache) GetByTransaction(transaction string) []models.Order {
+	return c.gather(func(ix indexes) index { return ix.byTransaction }, transaction)
+}
+
+func (c *ShardedCache) gather(pick func(indexes) index, key string) []models.Order {
+	var orders []models.Order
+	for _, s := range c.shards {
+		orders = append(orders, s.collect(pick, key)...)
+	}
+	if len(orders) == 0 {
+		c.misses.Add(1)
+	} else {
+		c.hits.Add(1)
+	}
+	return orders
+}
+
+func (c *ShardedCache) StartRefresher(ctx context.Context, load Loader) {
+	runRefresher(ctx, c.cfg, func() {
+		for _, s := range c.shards {
+			s.refresh(ctx, load)
+		}
+	})
+}
+
+func (c *ShardedCache) SaveSnapshot(path string) error {
+	return saveSnapshot(c, path)
+}
+
+func (c *ShardedCache) LoadSnapshot(path string, maxAge time.Duration) (time.Time, error) {
+	return loadSnapshot(c, path, maxAge)
+}
+
+func (c *ShardedCache) StartSnapshots(ctx context.Context, path string, interval time.Duration) {
+	runSnapshots(ctx, c, path, interval)
+}
@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"unsafe"
+	"wb-task-L0/pkg/models"
+)
+
+var (
+	orderHeaderSize = int64(unsafe.Sizeof(models.Order{}))
+	itemHeaderSize  = int64(unsafe.Sizeof(models.Item{}))
+)
+
+// EstimateSize approximates the memory held by an order: struct headers plus
+// string contents. It is cheap and good enough for a memory budget.
+func EstimateSize(o *models.Order) int64 {
+	size := orderHeaderSize + int64(len(o.OrderUID)+len(o.TrackNumber)+len(o.Entry)+len(o.Locale)+
+		len(o.InternalSignature)+len(o.CustomerID)+len(o.DeliveryService)+len(o.ShardKey)+len(o.OofShard))
+
+	d := &o.Delivery
+	size += int64(len(d.DeliveryID) + len(d.OrderUID) + len(d.Name) + len(d.Phone) + len(d.Zip) +
+		len(d.City) + len(d.Address) + len(d.Region) + len(d.Email))
+
+	p := &o.Payment
+	size += int64(len(p.PaymentID) + len(p.OrderUID) + len(p.Transaction) + len(p.RequestID) +
+		len(p.Currency) + len(p.Provider) + len(p.Bank))
+
+	for i := range o.Items {
+		it := &o.Items[i]
+		size += itemHeaderSize + int64(len(it.ItemID)+len(it.OrderUID)+len(it.TrackNumber)+
+			len(it.Rid)+len(it.Name)+len(it.Size)+len(it.Brand))
+	}
+
+	return size
+}
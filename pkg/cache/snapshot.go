@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+	"wb-task-L0/pkg/models"
+)
+
+// Snapshot file layout, integers big endian:
+//
+//	magic "OCSN" | version uint16 | payload length uint64 | crc32c uint32 | payload
+//
+// The payload is a gob encoded snapshotFile. Bump snapshotVersion whenever
+// models.Order changes in a way gob cannot bridge, or old entries would be
+// wrong rather than unreadable (version 2 added Revision, which decodes as 0).
+const (
+	snapshotMagic   = "OCSN"
+	snapshotVersion = 2
+	headerSize      = len(snapshotMagic) + 2 + 8 + 4
+
+	defaultSnapshotInterval = 5 * time.Minute
+)
+
+var (
+	ErrSnapshotCorrupt = errors.New("cache snapshot is corrupt")
+	ErrSnapshotVersion = errors.New("cache snapshot has an unsupported version")
+	ErrSnapshotStale   = errors.New("cache snapshot is too old")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+type snapshotFile struct {
+	// Watermark is taken before the entries are copied, so every change
+	// missing from the snapshot happened after it.
+	Watermark time.Time
+	Orders    []models.Order
+}
+
+// SaveSnapshot writes the cache contents to path. The file is replaced
+// atomically, a crash mid-write leaves the previous snapshot intact.
+func (c *OrderCache) SaveSnapshot(path string) error {
+	return saveSnapshot(c, path)
+}
+
+func saveSnapshot(c Cache, path string) error {
+	snap := snapshotFile{Watermark: time.Now(), Orders: c.GetAll()}
+
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(&snap); err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, snapshotMagic...)
+	header = binary.BigEndian.AppendUint16(header, snapshotVersion)
+	header = binary.BigEndian.AppendUint64(header, uint64(payload.Len()))
+	header = binary.BigEndian.AppendUint32(header, crc32.Checksum(payload.Bytes(), crcTable))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if _, err := w.Write(header); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := payload.WriteTo(w); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot adds the orders from a snapshot written by SaveSnapshot and
+// returns its watermark. Entries already in the cache are kept. Snapshots
+// older than maxAge (if set), with a bad checksum or a different version
+// are rejected without touching the cache.
+func (c *OrderCache) LoadSnapshot(path string, maxAge time.Duration) (time.Time, error) {
+	return loadSnapshot(c, path, maxAge)
+}
+
+func loadSnapshot(c Cache, path string, maxAge time.Duration) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return time.Time{}, fmt.Errorf("%w: short header", ErrSnapshotCorrupt)
+	}
+	if string(header[:4]) != snapshotMagic {
+		return time.Time{}, fmt.Errorf("%w: bad magic", ErrSnapshotCorrupt)
+	}
+	if v := binary.BigEndian.Uint16(header[4:6]); v != snapshotVersion {
+		return time.Time{}, fmt.Errorf("%w: %d", ErrSnapshotVersion, v)
+	}
+	size := binary.BigEndian.Uint64(header[6:14])
+	sum := binary.BigEndian.Uint32(header[14:18])
+
+	if st, err := f.Stat(); err != nil {
+		return time.Time{}, err
+	} else if uint64(st.Size()-int64(headerSize)) != size {
+		return time.Time{}, fmt.Errorf("%w: payload is %d bytes, header says %d", ErrSnapshotCorrupt, st.Size()-int64(headerSize), size)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(f, payload); err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	if crc32.Checksum(payload, crcTable) != sum {
+		return time.Time{}, fmt.Errorf("%w: checksum mismatch", ErrSnapshotCorrupt)
+	}
+
+	var snap snapshotFile
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&snap); err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	if maxAge > 0 && time.Since(snap.Watermark) > maxAge {
+		return time.Time{}, fmt.Errorf("%w: taken at %s", ErrSnapshotStale, snap.Watermark.Format(time.RFC3339))
+	}
+
+	for _, o := range snap.Orders {
+		c.setIfAbsent(o)
+	}
+	return snap.Watermark, nil
+}
+
+// StartSnapshots saves a snapshot every interval until ctx is done. The
+// final snapshot on shutdown is up to the caller, after writers stopped.
+func (c *OrderCache) StartSnapshots(ctx context.Context, path string, interval time.Duration) {
+	runSnapshots(ctx, c, path, interval)
+}
+
+func runSnapshots(ctx context.Context, c Cache, path string, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultSnapshotInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.SaveSnapshot(path); err != nil {
+				log.Printf("cache: failed to save snapshot: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+	"wb-task-L0/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultWarmupBatch = 500
+	warmupLogInterval  = 5 * time.Second
+)
+
+// PageLoader returns up to limit orders newest first, continuing after the
+// given order (nil for the first page) and skipping orders created before a
+// non-zero since. OrderRepo.GetPage has this shape.
+type PageLoader func(ctx context.Context, after *models.Order, since time.Time, limit int) ([]models.Order, error)
+
+// Counter estimates how many orders a warm-up will visit, for progress
+// reporting only.
+type Counter func(ctx context.Context, since time.Time) (int64, error)
+
+// ChangeLoader lists orders written or deleted at or after since.
+// OutboxRepo.ChangedSince has this shape.
+type ChangeLoader func(ctx context.Context, since time.Time) (changed, deleted []string, err error)
+
+type WarmupConfig struct {
+	BatchSize int
+	// MaxOrders stops after the most recent N orders, zero means all.
+	MaxOrders int
+	// MaxAge skips orders older than this, zero means all.
+	MaxAge time.Duration
+}
+
+type WarmupState string
+
+const (
+	WarmupPending WarmupState = "pending"
+	WarmupRunning WarmupState = "warming"
+	WarmupDone    WarmupState = "ready"
+	WarmupFailed  WarmupState = "failed"
+)
+
+type WarmupProgress struct {
+	State   WarmupState `json:"state"`
+	Loaded  int         `json:"loaded"`
+	Total   int64       `json:"total"`
+	Batches int         `json:"batches"`
+	Percent float64     `json:"percent"`
+	Elapsed string      `json:"elapsed"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// Warmer fills the cache page by page, so memory never holds more than one
+// page beyond what the cache itself keeps. Reads served meanwhile fall
+// through to the database as usual.
+//
+// The startup warm-up (Run or CatchUp) and later rebuilds report progress
+// separately, so only the first one decides whether the instance is ready.
+// Runs are serialized, a Rebuild waits for the one in flight.
+type Warmer struct {
+	cache Cache
+	cfg   WarmupConfig
+	load  PageLoader
+	count Counter
+
+	running sync.Mutex
+	warmup  tracker
+	rebuild tracker
+}
+
+// tracker is the progress of one kind of run.
+type tracker struct {
+	mu       sync.Mutex
+	progress WarmupProgress
+	started  time.Time
+	finished time.Time
+}
+
+func NewWarmer(c Cache, cfg WarmupConfig, load PageLoader, count Counter) *Warmer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultWarmupBatch
+	}
+	return &Warmer{
+		cache:   c,
+		cfg:     cfg,
+		load:    load,
+		count:   count,
+		warmup:  tracker{progress: WarmupProgress{State: WarmupPending}},
+		rebuild: tracker{progress: WarmupProgress{State: WarmupPending}},
+	}
+}
+
+// Run warms the cache in place, which is what startup wants: the cache is
+// empty and every loaded page is usable right away.
+func (w *Warmer) Run(ctx context.Context) error {
+	w.running.Lock()
+	defer w.running.Unlock()
+	return w.run(ctx, w.cache, &w.warmup)
+}
+
+// Rebuild loads into an empty cache and swaps it in when done, so orders
+// deleted since the last load disappear too. Until then the old contents
+// keep being served, and whatever is written to them meanwhile is applied
+// to the new contents on swap. Its progress is reported by RebuildProgress.
+func (w *Warmer) Rebuild(ctx context.Context) error {
+	w.running.Lock()
+	defer w.running.Unlock()
+
+	fresh := w.cache.empty()
+	w.cache.record()
+	if err := w.run(ctx, fresh, &w.rebuild); err != nil {
+		w.cache.discard()
+		return err
+	}
+	w.cache.swap(fresh)
+	return nil
+}
+
+// CatchUp brings a cache restored from a snapshot up to date by reloading
+// only the orders that changed since its watermark and dropping deleted ones.
+func (w *Warmer) CatchUp(ctx context.Context, since time.Time, changes ChangeLoader, load Loader) error {
+	w.running.Lock()
+	defer w.running.Unlock()
+
+	t := &w.warmup
+	t.start(0)
+
+	changed, deleted, err := changes(ctx, since)
+	if err != nil {
+		t.finish(err)
+		return err
+	}
+	t.mu.Lock()
+	t.progress.Total = int64(len(changed) + len(deleted))
+	t.mu.Unlock()
+
+	for _, id := range deleted {
+		w.cache.Delete(id)
+	}
+	t.advance(len(deleted))
+
+	for _, id := range changed {
+		if err := ctx.Err(); err != nil {
+			t.finish(err)
+			return err
+		}
+		order, err := load(id)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			w.cache.Delete(id)
+		case err != nil:
+			t.finish(err)
+			return err
+		default:
+			w.cache.SetIfNewer(order)
+		}
+		t.advance(1)
+	}
+
+	t.finish(nil)
+	log.Printf("cache caught up since %s: %d changed, %d deleted", since.Format(time.RFC3339), len(changed), len(deleted))
+	return nil
+}
+
+func (w *Warmer) run(ctx context.Context, dst Cache, t *tracker) error {
+	var since time.Time
+	if w.cfg.MaxAge > 0 {
+		since = time.Now().Add(-w.cfg.MaxAge)
+	}
+
+	t.start(w.total(ctx, since))
+
+	var after *models.Order
+	lastLog := time.Now()
+	for w.cfg.MaxOrders <= 0 || t.loaded() < w.cfg.MaxOrders {
+		limit := w.cfg.BatchSize
+		if w.cfg.MaxOrders > 0 {
+			limit = min(limit, w.cfg.MaxOrders-t.loaded())
+		}
+
+		page, err := w.load(ctx, after, since, limit)
+		if err != nil {
+			t.finish(err)
+			return err
+		}
+		stored := 0
+		for _, o := range page {
+			if dst.setIfAbsent(o) {
+				stored++
+			}
+		}
+		t.advance(len(page))
+		// keep going while some shard still has room, stop at the first
+		// page that fits nowhere
+		if stored == 0 && len(page) > 0 {
+			break
+		}
+
+		if time.Since(lastLog) >= warmupLogInterval {
+			p := t.snapshot()
+			log.Printf("cache warm-up: %d/%d orders (%.1f%%)", p.Loaded, p.Total, p.Percent)
+			lastLog = time.Now()
+		}
+
+		if len(page) < limit {
+			break
+		}
+		after = &page[len(page)-1]
+	}
+
+	t.finish(nil)
+	p := t.snapshot()
+	log.Printf("cache warm-up finished: %d orders in %s", p.Loaded, p.Elapsed)
+	return nil
+}
+
+func (w *Warmer) total(ctx context.Context, since time.Time) int64 {
+	if w.count == nil {
+		return 0
+	}
+	n, err := w.count(ctx, since)
+	if err != nil {
+		log.Printf("cache warm-up: failed to count orders: %v", err)
+		return 0
+	}
+	if w.cfg.MaxOrders > 0 && n > int64(w.cfg.MaxOrders) {
+		n = int64(w.cfg.MaxOrders)
+	}
+	return n
+}
+
+func (t *tracker) start(total int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress = WarmupProgress{State: WarmupRunning, Total: total}
+	t.started, t.finished = time.Now(), time.Time{}
+}
+
+func (t *tracker) advance(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Loaded += n
+	t.progress.Batches++
+}
+
+func (t *tracker) loaded() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.progress.Loaded
+}
+
+func (t *tracker) finish(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.finished = time.Now()
+	if err != nil {
+		t.progress.State = WarmupFailed
+		t.progress.Error = err.Error()
+		return
+	}
+	t.progress.State = WarmupDone
+}
+
+func (t *tracker) snapshot() WarmupProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.progress
+	switch {
+	case p.State == WarmupDone:
+		p.Percent = 100
+	case p.Total > 0:
+		p.Percent = min(100, float64(p.Loaded)*100/float64(p.Total))
+	}
+	if !t.started.IsZero() {
+		end := t.finished
+		if end.IsZero() {
+			end = time.Now()
+		}
+		p.Elapsed = end.Sub(t.started).Round(time.Millisecond).String()
+	}
+	return p
+}
+
+// Progress reports the startup warm-up, Run or CatchUp. It is safe to call
+// while the warm-up runs.
+func (w *Warmer) Progress() WarmupProgress {
+	return w.warmup.snapshot()
+}
+
+// RebuildProgress reports the last or current Rebuild.
+func (w *Warmer) RebuildProgress() WarmupProgress {
+	return w.rebuild.snapshot()
+}
@@ -0,0 +1,46 @@
+package codec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"wb-task-L0/pkg/models"
+
+	"github.com/hamba/avro/v2"
+)
+
+const avroSchemaFile = "order.avsc"
+
+// AvroCodec reads Avro binary payloads using the order schema from the local
+// schema directory, so no schema registry is needed.
+type AvroCodec struct {
+	schema avro.Schema
+}
+
+func NewAvroCodec(schemaDir string) (*AvroCodec, error) {
+	path := filepath.Join(schemaDir, avroSchemaFile)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read avro schema: %w", err)
+	}
+
+	schema, err := avro.Parse(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("parse avro schema %s: %w", path, err)
+	}
+	return &AvroCodec{schema: schema}, nil
+}
+
+func (c *AvroCodec) Name() string { return Avro }
+
+func (c *AvroCodec) Decode(data []byte) (models.Order, error) {
+	var order models.Order
+	if err := avro.Unmarshal(c.schema, data, &order); err != nil {
+		return models.Order{}, err
+	}
+	return order, nil
+}
+
+func (c *AvroCodec) Encode(order *models.Order) ([]byte, error) {
+	return avro.Marshal(c.schema, order)
+}
@@ -0,0 +1,111 @@
+package codec
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"wb-task-L0/pkg/models"
+)
+
+const (
+	JSON     = "json"
+	Protobuf = "protobuf"
+	Avro     = "avro"
+)
+
+// HeaderContentType is the Kafka message header that selects a codec for a
+// single message, overriding the configured default.
+const HeaderContentType = "content-type"
+
+type Codec interface {
+	Name() string
+	Decode(data []byte) (models.Order, error)
+	Encode(order *models.Order) ([]byte, error)
+}
+
+// contentTypes maps header values and config names to codec names.
+var contentTypes = map[string]string{
+	"json":                               JSON,
+	"application/json":                   JSON,
+	"protobuf":                           Protobuf,
+	"proto":                              Protobuf,
+	"application/protobuf":               Protobuf,
+	"application/x-protobuf":             Protobuf,
+	"avro":                               Avro,
+	"avro/binary":                        Avro,
+	"application/avro":                   Avro,
+	"application/vnd.apache.avro+binary": Avro,
+}
+
+type Registry struct {
+	// codecs build their codec on first use, see NewRegistry
+	codecs map[string]func() (Codec, error)
+	def    Codec
+}
+
+// NewRegistry knows every codec and uses defaultName (a codec name or
+// content type) when a message has no content-type header. Codecs that need
+// a schema from schemaDir load it on first use, so a missing schema only
+// fails the messages that need it. The default codec is loaded right away,
+// a broken default is a config error.
+func NewRegistry(defaultName, schemaDir string) (*Registry, error) {
+	r := &Registry{
+		codecs: map[string]func() (Codec, error){
+			JSON: func() (Codec, error) { return JSONCodec{}, nil },
+			Protobuf: sync.OnceValues(func() (Codec, error) {
+				c, err := NewProtobufCodec(schemaDir)
+				if err != nil {
+					return nil, err
+				}
+				return c, nil
+			}),
+			Avro: sync.OnceValues(func() (Codec, error) {
+				c, err := NewAvroCodec(schemaDir)
+				if err != nil {
+					return nil, err
+				}
+				return c, nil
+			}),
+		},
+	}
+	if defaultName == "" {
+		defaultName = JSON
+	}
+	var err error
+	if r.def, err = r.Resolve(defaultName); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// NewJSONRegistry returns a registry that only knows JSON, which needs no
+// schemas.
+func NewJSONRegistry() *Registry {
+	return &Registry{
+		codecs: map[string]func() (Codec, error){
+			JSON: func() (Codec, error) { return JSONCodec{}, nil },
+		},
+		def: JSONCodec{},
+	}
+}
+
+// Resolve returns the codec for a content type; an empty value selects the
+// default codec. Parameters such as "; charset=utf-8" are ignored. A schema
+// codec that failed to load keeps failing.
+func (r *Registry) Resolve(contentType string) (Codec, error) {
+	ct, _, _ := strings.Cut(contentType, ";")
+	ct = strings.ToLower(strings.TrimSpace(ct))
+	if ct == "" {
+		return r.def, nil
+	}
+
+	load, ok := r.codecs[contentTypes[ct]]
+	if !ok {
+		return nil, fmt.Errorf("unsupported content type %q", contentType)
+	}
+	c, err := load()
+	if err != nil {
+		return nil, fmt.Errorf("%s codec is unavailable: %w", contentTypes[ct], err)
+	}
+	return c, nil
+}
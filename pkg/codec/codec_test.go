@@ -0,0 +1,141 @@
+package codec_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"wb-task-L0/pkg/codec"
+	"wb-task-L0/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOrder() models.Order {
+	return models.Order{
+		OrderUID:     "order123",
+		TrackNumber:  "track456",
+		Entry:        "WBIL",
+		Locale:       "en",
+		CustomerID:   "cust1",
+		SmID:         99,
+		DateCreated:  time.Date(2025, 9, 1, 4, 0, 0, 0, time.UTC),
+		OofShard:     "1",
+		EventVersion: 3,
+		Delivery: models.Delivery{
+			DeliveryID: "del1",
+			Name:       "John",
+			Phone:      "+1234567890",
+			City:       "Moscow",
+			Address:    "Red Square, 1",
+		},
+		Payment: models.Payment{
+			PaymentID:    "pay1",
+			Transaction:  "tx123",
+			Currency:     "RUB",
+			Provider:     "wbpay",
+			Amount:       1500.5,
+			PaymentDt:    1735728000,
+			DeliveryCost: 200,
+			GoodsTotal:   1300.5,
+		},
+		Items: []models.Item{
+			{ItemID: "it1", ChrtID: 12345, Price: 500.25, Name: "T-Shirt", Sale: 10, Status: 202},
+			{ItemID: "it2", ChrtID: 67890, Price: 800.25, Name: "Jeans", Sale: 5, Status: -1},
+		},
+	}
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	registry, err := codec.NewRegistry(codec.JSON, "../../schemas")
+	require.NoError(t, err)
+
+	for _, ct := range []string{"application/json", "application/x-protobuf", "avro/binary"} {
+		t.Run(ct, func(t *testing.T) {
+			c, err := registry.Resolve(ct)
+			require.NoError(t, err)
+
+			order := testOrder()
+			data, err := c.Encode(&order)
+			require.NoError(t, err)
+
+			got, err := c.Decode(data)
+			require.NoError(t, err)
+			assert.True(t, order.DateCreated.Equal(got.DateCreated))
+			got.DateCreated = order.DateCreated
+			assert.Equal(t, order, got)
+		})
+	}
+}
+
+func TestRegistry_Resolve(t *testing.T) {
+	registry, err := codec.NewRegistry("protobuf", "../../schemas")
+	require.NoError(t, err)
+
+	c, err := registry.Resolve("")
+	require.NoError(t, err)
+	assert.Equal(t, codec.Protobuf, c.Name())
+
+	c, err = registry.Resolve("Application/JSON; charset=utf-8")
+	require.NoError(t, err)
+	assert.Equal(t, codec.JSON, c.Name())
+
+	_, err = registry.Resolve("text/xml")
+	assert.Error(t, err)
+
+	_, err = registry.Resolve("text/xml")
+	assert.Error(t, err)
+}
+
+func TestRegistry_LoadsSchemasLazily(t *testing.T) {
+	registry, err := codec.NewRegistry(codec.JSON, t.TempDir())
+	require.NoError(t, err, "schemas are not needed until a message uses them")
+
+	_, err = registry.Resolve("avro/binary")
+	assert.ErrorContains(t, err, "avro codec is unavailable")
+	_, err = registry.Resolve("application/x-protobuf")
+	assert.ErrorContains(t, err, "protobuf codec is unavailable")
+
+	_, err = codec.NewRegistry(codec.Avro, t.TempDir())
+	assert.Error(t, err, "the default codec is loaded at startup")
+}
+
+// TestProtobufCodec_FollowsSchema checks that field numbers come from the
+// .proto file: the same order encodes differently under a renumbered schema.
+func TestProtobufCodec_FollowsSchema(t *testing.T) {
+	raw, err := os.ReadFile("../../schemas/order.proto")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	renumbered := strings.Replace(string(raw), "string track_number = 2;", "string track_number = 20;", 1)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "order.proto"), []byte(renumbered), 0o644))
+
+	original, err := codec.NewProtobufCodec("../../schemas")
+	require.NoError(t, err)
+	moved, err := codec.NewProtobufCodec(dir)
+	require.NoError(t, err)
+
+	order := testOrder()
+	data, err := moved.Encode(&order)
+	require.NoError(t, err)
+
+	got, err := moved.Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, order.TrackNumber, got.TrackNumber)
+
+	got, err = original.Decode(data)
+	require.NoError(t, err)
+	assert.Empty(t, got.TrackNumber, "field 20 is unknown to the original schema")
+	assert.Equal(t, order.OrderUID, got.OrderUID)
+}
+
+func TestProtobufCodec_SchemaMustFitModel(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "order.proto"),
+		[]byte(`syntax = "proto3"; package orders.v1; message Order { int64 order_uid = 1; }`), 0o644))
+
+	_, err := codec.NewProtobufCodec(dir)
+	assert.ErrorContains(t, err, "does not fit model type")
+}
@@ -0,0 +1,22 @@
+package codec
+
+import (
+	"encoding/json"
+	"wb-task-L0/pkg/models"
+)
+
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string { return JSON }
+
+func (JSONCodec) Decode(data []byte) (models.Order, error) {
+	var order models.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return models.Order{}, err
+	}
+	return order, nil
+}
+
+func (JSONCodec) Encode(order *models.Order) ([]byte, error) {
+	return json.Marshal(order)
+}
@@ -0,0 +1,244 @@
+package codec
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"wb-task-L0/pkg/models"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	protoSchemaFile = "order.proto"
+	protoMessage    = "orders.v1.Order"
+	timestampName   = "google.protobuf.Timestamp"
+)
+
+// ProtobufCodec reads and writes the wire format described by
+// schemas/order.proto, which is compiled at startup. Proto fields are
+// matched to model fields by name (the json tag), so field numbers live
+// only in the .proto file. Unknown fields are skipped, so producers may add
+// fields without breaking us.
+type ProtobufCodec struct {
+	desc protoreflect.MessageDescriptor
+}
+
+func NewProtobufCodec(schemaDir string) (*ProtobufCodec, error) {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			ImportPaths: []string{schemaDir},
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), protoSchemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("compile protobuf schema: %w", err)
+	}
+
+	d, err := files.AsResolver().FindDescriptorByName(protoMessage)
+	desc, ok := d.(protoreflect.MessageDescriptor)
+	if err != nil || !ok {
+		return nil, fmt.Errorf("protobuf schema %s has no message %s", protoSchemaFile, protoMessage)
+	}
+	if err := checkFields(reflect.TypeOf(models.Order{}), desc); err != nil {
+		return nil, fmt.Errorf("protobuf schema %s: %w", protoSchemaFile, err)
+	}
+	return &ProtobufCodec{desc: desc}, nil
+}
+
+func (c *ProtobufCodec) Name() string { return Protobuf }
+
+func (c *ProtobufCodec) Decode(data []byte) (models.Order, error) {
+	msg := dynamicpb.NewMessage(c.desc)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return models.Order{}, err
+	}
+
+	var o models.Order
+	if err := fromProto(msg, reflect.ValueOf(&o).Elem()); err != nil {
+		return models.Order{}, err
+	}
+	return o, nil
+}
+
+func (c *ProtobufCodec) Encode(o *models.Order) ([]byte, error) {
+	msg := dynamicpb.NewMessage(c.desc)
+	if err := toProto(reflect.ValueOf(o).Elem(), msg); err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
+
+// matchFields pairs the fields of a model struct with the proto fields of
+// the same name (the json tag). Model fields without a counterpart are left
+// alone, as are proto fields the model doesn't have.
+func matchFields(t reflect.Type, desc protoreflect.MessageDescriptor, visit func(int, protoreflect.FieldDescriptor) error) error {
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fd := desc.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			continue
+		}
+		if err := visit(i, fd); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// checkFields makes sure every matched proto field fits its model field, so
+// encoding and decoding can't fail on types later.
+func checkFields(t reflect.Type, desc protoreflect.MessageDescriptor) error {
+	return matchFields(t, desc, func(i int, fd protoreflect.FieldDescriptor) error {
+		ft := t.Field(i).Type
+		if fd.IsList() {
+			if ft.Kind() != reflect.Slice || fd.Kind() != protoreflect.MessageKind {
+				return errFieldType(fd, ft)
+			}
+			return checkFields(ft.Elem(), fd.Message())
+		}
+		if fd.IsMap() {
+			return errFieldType(fd, ft)
+		}
+
+		switch fd.Kind() {
+		case protoreflect.StringKind:
+			if ft.Kind() == reflect.String {
+				return nil
+			}
+		case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+			protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+			if ft.Kind() >= reflect.Int && ft.Kind() <= reflect.Int64 {
+				return nil
+			}
+		case protoreflect.DoubleKind, protoreflect.FloatKind:
+			if ft.Kind() == reflect.Float64 || ft.Kind() == reflect.Float32 {
+				return nil
+			}
+		case protoreflect.MessageKind:
+			if fd.Message().FullName() == timestampName {
+				if ft == reflect.TypeOf(time.Time{}) {
+					return nil
+				}
+			} else if ft.Kind() == reflect.Struct {
+				return checkFields(ft, fd.Message())
+			}
+		}
+		return errFieldType(fd, ft)
+	})
+}
+
+func fromProto(msg protoreflect.Message, dst reflect.Value) error {
+	return matchFields(dst.Type(), msg.Descriptor(), func(i int, fd protoreflect.FieldDescriptor) error {
+		if !msg.Has(fd) {
+			return nil
+		}
+		f, val := dst.Field(i), msg.Get(fd)
+
+		switch {
+		case fd.IsList():
+			list := val.List()
+			s := reflect.MakeSlice(f.Type(), list.Len(), list.Len())
+			for j := 0; j < list.Len(); j++ {
+				if err := fromProto(list.Get(j).Message(), s.Index(j)); err != nil {
+					return err
+				}
+			}
+			f.Set(s)
+		case fd.Kind() == protoreflect.StringKind:
+			f.SetString(val.String())
+		case fd.Kind() == protoreflect.DoubleKind, fd.Kind() == protoreflect.FloatKind:
+			f.SetFloat(val.Float())
+		case fd.Message() != nil && fd.Message().FullName() == timestampName:
+			f.Set(reflect.ValueOf(timestampFromProto(val.Message())))
+		case fd.Message() != nil:
+			return fromProto(val.Message(), f)
+		default:
+			f.SetInt(val.Int())
+		}
+		return nil
+	})
+}
+
+func toProto(src reflect.Value, msg protoreflect.Message) error {
+	return matchFields(src.Type(), msg.Descriptor(), func(i int, fd protoreflect.FieldDescriptor) error {
+		f := src.Field(i)
+
+		var val protoreflect.Value
+		switch fd.Kind() {
+		case protoreflect.MessageKind:
+			switch {
+			case fd.IsList():
+				list := msg.Mutable(fd).List()
+				for j := 0; j < f.Len(); j++ {
+					elem := list.NewElement()
+					if err := toProto(f.Index(j), elem.Message()); err != nil {
+						return err
+					}
+					list.Append(elem)
+				}
+				return nil
+			case fd.Message().FullName() == timestampName:
+				t := f.Interface().(time.Time)
+				if t.IsZero() {
+					return nil
+				}
+				val = protoreflect.ValueOfMessage(timestampToProto(msg.NewField(fd).Message(), t))
+			default:
+				sub := msg.NewField(fd).Message()
+				if err := toProto(f, sub); err != nil {
+					return err
+				}
+				val = protoreflect.ValueOfMessage(sub)
+			}
+			msg.Set(fd, val)
+			return nil
+		case protoreflect.StringKind:
+			val = protoreflect.ValueOfString(f.String())
+		case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+			val = protoreflect.ValueOfInt32(int32(f.Int()))
+		case protoreflect.DoubleKind:
+			val = protoreflect.ValueOfFloat64(f.Float())
+		case protoreflect.FloatKind:
+			val = protoreflect.ValueOfFloat32(float32(f.Float()))
+		default:
+			val = protoreflect.ValueOfInt64(f.Int())
+		}
+
+		// proto3 leaves default values off the wire, like generated code
+		if !val.Equal(fd.Default()) {
+			msg.Set(fd, val)
+		}
+		return nil
+	})
+}
+
+// google.protobuf.Timestamp is read through its descriptor, dynamicpb
+// messages can't be converted to *timestamppb.Timestamp.
+func timestampFromProto(m protoreflect.Message) time.Time {
+	fields := m.Descriptor().Fields()
+	seconds := m.Get(fields.ByName("seconds")).Int()
+	nanos := m.Get(fields.ByName("nanos")).Int()
+	return time.Unix(seconds, nanos).UTC()
+}
+
+func timestampToProto(m protoreflect.Message, t time.Time) protoreflect.Message {
+	fields := m.Descriptor().Fields()
+	m.Set(fields.ByName("seconds"), protoreflect.ValueOfInt64(t.Unix()))
+	if nanos := t.Nanosecond(); nanos != 0 {
+		m.Set(fields.ByName("nanos"), protoreflect.ValueOfInt32(int32(nanos)))
+	}
+	return m
+}
+
+func errFieldType(fd protoreflect.FieldDescriptor, t reflect.Type) error {
+	return fmt.Errorf("proto field %s (%s) does not fit model type %s", fd.FullName(), fd.Kind(), t)
+}
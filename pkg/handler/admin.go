@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultKeysLimit = 100
+	maxKeysLimit     = 1000
+)
+
+// adminAuth accepts "Authorization: Bearer <ADMIN_TOKEN>". Without a
+// configured token the admin API is closed.
+func (h *Handler) adminAuth(c *gin.Context) {
+	if h.adminToken == "" {
+		newErrorResponse(c, http.StatusForbidden, "admin API is disabled")
+		return
+	}
+
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+		c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+		newErrorResponse(c, http.StatusUnauthorized, "invalid admin credentials")
+		return
+	}
+	c.Next()
+}
+
+func (h *Handler) cacheStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.services.CacheAdmin.Stats())
+}
+
+type cacheKeysResponse struct {
+	Data   []string `json:"data"`
+	Total  int      `json:"total"`
+	Offset int      `json:"offset"`
+	Limit  int      `json:"limit"`
+}
+
+func (h *Handler) cacheKeys(c *gin.Context) {
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		newErrorResponse(c, http.StatusBadRequest, "invalid offset param")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultKeysLimit)))
+	if err != nil || limit <= 0 || limit > maxKeysLimit {
+		newErrorResponse(c, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxKeysLimit))
+		return
+	}
+
+	keys, total := h.services.CacheAdmin.Keys(c.Query("prefix"), offset, limit)
+	c.JSON(http.StatusOK, cacheKeysResponse{
+		Data:   keys,
+		Total:  total,
+		Offset: offset,
+		Limit:  limit,
+	})
+}
+
+func (h *Handler) evictCacheKey(c *gin.Context) {
+	if !h.services.CacheAdmin.Evict(c.Param("id")) {
+		newErrorResponse(c, http.StatusNotFound, "order is not cached")
+		return
+	}
+	c.JSON(http.StatusOK, statusResponse{
+		Status: "ok",
+	})
+}
+
+func (h *Handler) evictCachePrefix(c *gin.Context) {
+	prefix := c.Query("prefix")
+	if prefix == "" {
+		newErrorResponse(c, http.StatusBadRequest, "prefix param is required, use reload to rebuild the whole cache")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"evicted": h.services.CacheAdmin.EvictPrefix(prefix),
+	})
+}
+
+type reloadInput struct {
+	IDs []string `json:"ids"`
+}
+
+// reloadCache refreshes the listed orders synchronously, or starts a full
+// rebuild in the background when no ids are given.
+func (h *Handler) reloadCache(c *gin.Context) {
+	var input reloadInput
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&input); err != nil {
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if len(input.IDs) == 0 {
+		if err := h.services.CacheAdmin.ReloadAll(); err != nil {
+			newServiceErrorResponse(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, statusResponse{
+			Status: "reloading",
+		})
+		return
+	}
+
+	res, err := h.services.CacheAdmin.Reload(c.Request.Context(), input.IDs)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"wb-task-L0/pkg/cache"
+	"wb-task-L0/pkg/service"
+	mock_service "wb-task-L0/pkg/service/mocks"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_AdminAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		token      string
+		header     string
+		wantStatus int
+	}{
+		{name: "disabled", token: "", header: "Bearer ", wantStatus: http.StatusForbidden},
+		{name: "missing", token: "secret", header: "", wantStatus: http.StatusUnauthorized},
+		{name: "wrong", token: "secret", header: "Bearer nope", wantStatus: http.StatusUnauthorized},
+		{name: "ok", token: "secret", header: "Bearer secret", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			admin := mock_service.NewMockCacheAdmin(ctrl)
+			if tt.wantStatus == http.StatusOK {
+				admin.EXPECT().Stats().Return(service.CacheStats{Stats: cache.Stats{Entries: 3}})
+			}
+
+			h := NewHandler(&service.Service{CacheAdmin: admin}, tt.token)
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/cache/stats", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.InitRoutes().ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.True(t, strings.Contains(w.Body.String(), `"entries":3`), w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_CacheKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	admin := mock_service.NewMockCacheAdmin(ctrl)
+	admin.EXPECT().Keys("ord", 10, 5).Return([]string{"ord-1"}, 11)
+
+	h := NewHandler(&service.Service{CacheAdmin: admin}, "secret")
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/cache/keys?prefix=ord&offset=10&limit=5", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	h.InitRoutes().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data":["ord-1"],"total":11,"offset":10,"limit":5}`, w.Body.String())
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+	"wb-task-L0/pkg/models"
+)
+
+// orderETag is the order revision as a strong entity tag, e.g. "3".
+func orderETag(order *models.Order) string {
+	return `"` + strconv.FormatInt(order.Revision, 10) + `"`
+}
+
+// parseIfMatch returns the revisions listed in an If-Match header, nil when
+// there is no header or it is "*". If-Match compares strongly, so weak and
+// malformed tags are dropped; a header with nothing else left yields an
+// empty, non-nil list that matches no revision.
+func parseIfMatch(header string) []int64 {
+	if strings.TrimSpace(header) == "" {
+		return nil
+	}
+	revisions := []int64{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil
+		}
+		unquoted, ok := strings.CutPrefix(tag, `"`)
+		if !ok {
+			continue
+		}
+		unquoted, ok = strings.CutSuffix(unquoted, `"`)
+		if !ok {
+			continue
+		}
+		if rev, err := strconv.ParseInt(unquoted, 10, 64); err == nil {
+			revisions = append(revisions, rev)
+		}
+	}
+	return revisions
+}
+
+// noneMatch reports whether an If-None-Match header lists etag, using the
+// weak comparison the header calls for.
+func noneMatch(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,14 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	assert.Nil(t, parseIfMatch(""))
+	assert.Nil(t, parseIfMatch("*"))
+	assert.Equal(t, []int64{3, 5}, parseIfMatch(`"3", "5"`))
+	assert.Equal(t, []int64{}, parseIfMatch(`W/"3", 4, "x"`), "weak and malformed tags never match")
+}
@@ -6,11 +6,12 @@ import (
 )
 
 type Handler struct {
-	services *service.Service
+	services   *service.Service
+	adminToken string
 }
 
-func NewHandler(services *service.Service) *Handler {
-	return &Handler{services: services}
+func NewHandler(services *service.Service, adminToken string) *Handler {
+	return &Handler{services: services, adminToken: adminToken}
 }
 
 func (h *Handler) InitRoutes() *gin.Engine {
@@ -22,8 +23,25 @@ func (h *Handler) InitRoutes() *gin.Engine {
 		{
 			orders.POST("/", h.createOrder)
 			orders.GET("/", h.getAllOrders)
+			orders.GET("/search", h.searchOrders)
 			orders.GET("/:id", h.getOrderById)
+			orders.PUT("/:id", h.replaceOrder)
+			orders.PATCH("/:id", h.patchOrder)
 			orders.DELETE("/:id", h.deleteOrder)
+			orders.GET("/track/:track_number", h.getOrdersByTrackNumber)
+			orders.GET("/transaction/:transaction", h.getOrdersByTransaction)
+		}
+
+		api.GET("/customers/:customer_id/orders", h.getOrdersByCustomer)
+
+		admin := api.Group("/admin", h.adminAuth)
+		{
+			cache := admin.Group("/cache")
+			cache.GET("/stats", h.cacheStats)
+			cache.GET("/keys", h.cacheKeys)
+			cache.DELETE("/keys", h.evictCachePrefix)
+			cache.DELETE("/keys/:id", h.evictCacheKey)
+			cache.POST("/reload", h.reloadCache)
 		}
 	}
 
@@ -1,10 +1,18 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"wb-task-L0/pkg/models"
+	"wb-task-L0/pkg/repository"
+	"wb-task-L0/pkg/validator"
 )
 
 func (h *Handler) createOrder(c *gin.Context) {
@@ -14,9 +22,14 @@ func (h *Handler) createOrder(c *gin.Context) {
 		return
 	}
 
+	if err := validator.ValidateOrder(&input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
 	order, err := h.services.Order.Create(&input)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
 		return
 	}
 
@@ -32,8 +45,13 @@ func (h *Handler) createOrderWithAssociations(c *gin.Context) {
 		return
 	}
 
+	if err := validator.ValidateOrder(&input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
 	if err := h.services.Order.CreateOrderWithAssociations(c.Request.Context(), &input); err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
 		return
 	}
 
@@ -43,19 +61,157 @@ func (h *Handler) createOrderWithAssociations(c *gin.Context) {
 }
 
 type getAllOrdersResponse struct {
-	Data []models.Order `json:"data"`
+	Data       []models.Order `json:"data"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	PrevCursor string         `json:"prev_cursor,omitempty"`
 }
 
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// getAllOrders returns one page of orders, newest first unless
+// sort=date_created. Follow next_cursor/prev_cursor with ?cursor= and the
+// same filters.
+//
+//	GET /api/orders?limit=20&customer_id=test&currency=USD&from=2025-09-01T00:00:00Z
 func (h *Handler) getAllOrders(c *gin.Context) {
-	orders, err := h.services.Order.GetAll()
+	q, err := parseOrderQuery(c)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, getAllOrdersResponse{
-		Data: orders,
-	})
+	page, err := h.services.Order.List(c.Request.Context(), q)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	resp := getAllOrdersResponse{Data: page.Orders}
+	if resp.Data == nil {
+		resp.Data = []models.Order{}
+	}
+	if page.Next != nil {
+		resp.NextCursor = page.Next.Encode()
+	}
+	if page.Prev != nil {
+		resp.PrevCursor = page.Prev.Encode()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func parseOrderQuery(c *gin.Context) (repository.OrderQuery, error) {
+	q := repository.OrderQuery{
+		CustomerID:      c.Query("customer_id"),
+		DeliveryService: c.Query("delivery_service"),
+		Locale:          c.Query("locale"),
+		Currency:        c.Query("currency"),
+		Provider:        c.Query("provider"),
+		Brand:           c.Query("brand"),
+		Limit:           defaultPageSize,
+	}
+
+	switch c.DefaultQuery("sort", "-date_created") {
+	case "-date_created":
+	case "date_created":
+		q.Ascending = true
+	default:
+		return q, errors.New("sort must be date_created or -date_created")
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxPageSize {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		q.Limit = limit
+	}
+	if v := c.Query("status"); v != "" {
+		status, err := strconv.Atoi(v)
+		if err != nil {
+			return q, errors.New("invalid status param")
+		}
+		q.Status = &status
+	}
+	for name, dst := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if v := c.Query(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return q, fmt.Errorf("%s must be an RFC3339 time", name)
+			}
+			*dst = t
+		}
+	}
+	if v := c.Query("cursor"); v != "" {
+		cursor, err := repository.DecodeCursor(v)
+		if err != nil {
+			return q, err
+		}
+		q.Cursor = &cursor
+	}
+	return q, nil
+}
+
+type searchOrdersResponse struct {
+	Data       []models.Order `json:"data"`
+	NextOffset *int           `json:"next_offset,omitempty"`
+}
+
+const (
+	minSearchLength = 2
+	maxSearchLength = 200
+	maxSearchOffset = 10000
+)
+
+// searchOrders looks q up in customer names, cities, addresses, emails,
+// phones and item names, tolerating typos. Results are ranked best first,
+// follow next_offset with ?offset= for the next page.
+//
+//	GET /api/orders/search?q=kira+ivanova&limit=20
+func (h *Handler) searchOrders(c *gin.Context) {
+	term := strings.TrimSpace(c.Query("q"))
+	if n := utf8.RuneCountInString(term); n < minSearchLength || n > maxSearchLength {
+		newErrorResponse(c, http.StatusBadRequest,
+			fmt.Sprintf("q must be between %d and %d characters", minSearchLength, maxSearchLength))
+		return
+	}
+
+	limit := defaultPageSize
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxPageSize {
+			newErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+			return
+		}
+		limit = n
+	}
+	offset := 0
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxSearchOffset {
+			newErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("offset must be between 0 and %d", maxSearchOffset))
+			return
+		}
+		offset = n
+	}
+
+	orders, more, err := h.services.Order.Search(c.Request.Context(), term, limit, offset)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	resp := searchOrdersResponse{Data: orders}
+	if resp.Data == nil {
+		resp.Data = []models.Order{}
+	}
+	if more {
+		next := offset + limit
+		resp.NextOffset = &next
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 func (h *Handler) getOrderById(c *gin.Context) {
@@ -67,10 +223,103 @@ func (h *Handler) getOrderById(c *gin.Context) {
 
 	order, err := h.services.Order.GetByID(id)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	// lets the frontend poll with If-None-Match and get 304 until it changes
+	etag := orderETag(&order)
+	c.Header("ETag", etag)
+	if noneMatch(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
 		return
 	}
+	c.JSON(http.StatusOK, order)
+}
 
+func (h *Handler) getOrdersByTrackNumber(c *gin.Context) {
+	orders, err := h.services.Order.GetByTrackNumber(c.Request.Context(), c.Param("track_number"))
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getAllOrdersResponse{
+		Data: orders,
+	})
+}
+
+func (h *Handler) getOrdersByTransaction(c *gin.Context) {
+	orders, err := h.services.Order.GetByTransaction(c.Request.Context(), c.Param("transaction"))
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getAllOrdersResponse{
+		Data: orders,
+	})
+}
+
+func (h *Handler) getOrdersByCustomer(c *gin.Context) {
+	orders, err := h.services.Order.GetByCustomer(c.Request.Context(), c.Param("customer_id"))
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getAllOrdersResponse{
+		Data: orders,
+	})
+}
+
+// replaceOrder overwrites an order with the request body. order_uid may be
+// omitted, but not changed. Like PATCH and DELETE it honours If-Match with
+// the ETag from GET and answers 412 when the order changed in between.
+//
+//	PUT /api/orders/:id
+func (h *Handler) replaceOrder(c *gin.Context) {
+	var input models.Order
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	order, err := h.services.Order.Replace(c.Request.Context(), c.Param("id"), input, parseIfMatch(c.GetHeader("If-Match")))
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+	c.Header("ETag", orderETag(&order))
+	c.JSON(http.StatusOK, order)
+}
+
+const mergePatchContentType = "application/merge-patch+json"
+
+// patchOrder applies a JSON Merge Patch to an order, e.g. to fix an address:
+//
+//	PATCH /api/orders/:id
+//	Content-Type: application/merge-patch+json
+//
+//	{"delivery": {"address": "Ploshad Mira 15"}}
+func (h *Handler) patchOrder(c *gin.Context) {
+	if ct := c.ContentType(); ct != mergePatchContentType && ct != gin.MIMEJSON {
+		newErrorResponse(c, http.StatusUnsupportedMediaType, "content type must be "+mergePatchContentType)
+		return
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	order, err := h.services.Order.Patch(c.Request.Context(), c.Param("id"), patch, parseIfMatch(c.GetHeader("If-Match")))
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+	c.Header("ETag", orderETag(&order))
 	c.JSON(http.StatusOK, order)
 }
 
@@ -81,9 +330,9 @@ func (h *Handler) deleteOrder(c *gin.Context) {
 		return
 	}
 
-	err := h.services.Order.Delete(id)
+	err := h.services.Order.Delete(c.Request.Context(), id, parseIfMatch(c.GetHeader("If-Match")))
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
 		return
 	}
 
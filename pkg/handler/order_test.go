@@ -0,0 +1,393 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"wb-task-L0/pkg/models"
+	"wb-task-L0/pkg/repository"
+	"wb-task-L0/pkg/service"
+	mock_service "wb-task-L0/pkg/service/mocks"
+	"wb-task-L0/pkg/validator"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestHandler_getAllOrders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	from := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	cursor := repository.Cursor{DateCreated: from, OrderUID: "a"}
+	status := 202
+
+	tests := []struct {
+		name       string
+		query      string
+		mock       func(m *mock_service.MockOrder)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:  "filters and cursor",
+			query: "?limit=1&sort=date_created&customer_id=c&currency=USD&brand=b&status=202&from=2025-09-01T00:00:00Z&cursor=" + cursor.Encode(),
+			mock: func(m *mock_service.MockOrder) {
+				m.EXPECT().List(gomock.Any(), repository.OrderQuery{
+					CustomerID: "c",
+					Currency:   "USD",
+					Brand:      "b",
+					Status:     &status,
+					From:       from,
+					Ascending:  true,
+					Cursor:     &cursor,
+					Limit:      1,
+				}).Return(repository.OrderPage{
+					Orders: []models.Order{{OrderUID: "b"}},
+					Next:   &repository.Cursor{DateCreated: from, OrderUID: "b"},
+				}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "bad limit",
+			query:      "?limit=100000",
+			mock:       func(m *mock_service.MockOrder) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "bad cursor",
+			query:      "?cursor=not-a-cursor",
+			mock:       func(m *mock_service.MockOrder) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "empty page",
+			query: "",
+			mock: func(m *mock_service.MockOrder) {
+				m.EXPECT().List(gomock.Any(), repository.OrderQuery{Limit: defaultPageSize}).Return(repository.OrderPage{}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"data":[]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			orders := mock_service.NewMockOrder(ctrl)
+			tt.mock(orders)
+
+			h := NewHandler(&service.Service{Order: orders}, "")
+			w := httptest.NewRecorder()
+			h.InitRoutes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/"+tt.query, nil))
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK && tt.wantBody == "" {
+				assert.Contains(t, w.Body.String(), `"next_cursor"`)
+			}
+		})
+	}
+}
+
+func TestHandler_searchOrders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		query      string
+		mock       func(m *mock_service.MockOrder)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:  "more results",
+			query: "?q=+ivanov+&limit=1&offset=5",
+			mock: func(m *mock_service.MockOrder) {
+				m.EXPECT().Search(gomock.Any(), "ivanov", 1, 5).
+					Return([]models.Order{{OrderUID: "b"}}, true, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "no results",
+			query: "?q=zzz",
+			mock: func(m *mock_service.MockOrder) {
+				m.EXPECT().Search(gomock.Any(), "zzz", defaultPageSize, 0).Return(nil, false, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"data":[]}`,
+		},
+		{
+			name:       "query too short",
+			query:      "?q=a",
+			mock:       func(m *mock_service.MockOrder) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "bad offset",
+			query:      "?q=ivanov&offset=-1",
+			mock:       func(m *mock_service.MockOrder) {},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			orders := mock_service.NewMockOrder(ctrl)
+			tt.mock(orders)
+
+			h := NewHandler(&service.Service{Order: orders}, "")
+			w := httptest.NewRecorder()
+			h.InitRoutes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/search"+tt.query, nil))
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK && tt.wantBody == "" {
+				assert.Contains(t, w.Body.String(), `"next_offset":6`)
+			}
+		})
+	}
+}
+
+func TestHandler_updateOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		ifMatch     string
+		body        string
+		mock        func(m *mock_service.MockOrder)
+		wantStatus  int
+		wantETag    string
+	}{
+		{
+			name:        "patch",
+			method:      http.MethodPatch,
+			contentType: "application/merge-patch+json",
+			ifMatch:     `"4"`,
+			body:        `{"delivery":{"address":"Ploshad Mira 15"}}`,
+			mock: func(m *mock_service.MockOrder) {
+				m.EXPECT().Patch(gomock.Any(), "order123", []byte(`{"delivery":{"address":"Ploshad Mira 15"}}`), []int64{4}).
+					Return(models.Order{OrderUID: "order123", Revision: 5}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantETag:   `"5"`,
+		},
+		{
+			name:        "replace changed order",
+			method:      http.MethodPut,
+			contentType: "application/json",
+			ifMatch:     `W/"4", "3"`,
+			body:        `{}`,
+			mock: func(m *mock_service.MockOrder) {
+				m.EXPECT().Replace(gomock.Any(), "order123", models.Order{}, []int64{3}).
+					Return(models.Order{}, &service.Error{Kind: service.ErrPreconditionFailed, Message: "order revision does not match"})
+			},
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:    "delete changed order",
+			method:  http.MethodDelete,
+			ifMatch: `"3"`,
+			mock: func(m *mock_service.MockOrder) {
+				m.EXPECT().Delete(gomock.Any(), "order123", []int64{3}).Return(&service.Error{Kind: service.ErrPreconditionFailed, Message: "order revision does not match"})
+			},
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:        "patch with unsupported content type",
+			method:      http.MethodPatch,
+			contentType: "text/plain",
+			body:        `{}`,
+			mock:        func(m *mock_service.MockOrder) {},
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "invalid patch",
+			method:      http.MethodPatch,
+			contentType: "application/json",
+			body:        `[]`,
+			mock: func(m *mock_service.MockOrder) {
+				m.EXPECT().Patch(gomock.Any(), "order123", gomock.Any(), nil).Return(models.Order{}, &service.Error{Kind: service.ErrInvalidPatch, Message: "patch must be a JSON object"})
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "immutable order_uid",
+			method:      http.MethodPut,
+			contentType: "application/json",
+			body:        `{"order_uid":"other"}`,
+			mock: func(m *mock_service.MockOrder) {
+				m.EXPECT().Replace(gomock.Any(), "order123", models.Order{OrderUID: "other"}, nil).
+					Return(models.Order{}, validator.Errors{{Field: "order_uid", Message: "is immutable"}})
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "replace missing order",
+			method:      http.MethodPut,
+			contentType: "application/json",
+			body:        `{}`,
+			mock: func(m *mock_service.MockOrder) {
+				m.EXPECT().Replace(gomock.Any(), "order123", models.Order{}, nil).Return(models.Order{}, &service.Error{Kind: service.ErrNotFound, Message: "order not found", Err: gorm.ErrRecordNotFound})
+			},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			orders := mock_service.NewMockOrder(ctrl)
+			tt.mock(orders)
+
+			h := NewHandler(&service.Service{Order: orders}, "")
+			req := httptest.NewRequest(tt.method, "/api/orders/order123", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			h.InitRoutes().ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			assert.Equal(t, tt.wantETag, w.Header().Get("ETag"))
+		})
+	}
+}
+
+func TestHandler_getOrderById_ETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctrl := gomock.NewController(t)
+	orders := mock_service.NewMockOrder(ctrl)
+	orders.EXPECT().GetByID("order123").Return(models.Order{OrderUID: "order123", Revision: 7}, nil).Times(3)
+	h := NewHandler(&service.Service{Order: orders}, "")
+
+	for ifNoneMatch, want := range map[string]int{
+		"":           http.StatusOK,
+		`"6"`:        http.StatusOK,
+		`"6", W/"7"`: http.StatusNotModified,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/orders/order123", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		h.InitRoutes().ServeHTTP(w, req)
+
+		assert.Equal(t, want, w.Code, ifNoneMatch)
+		assert.Equal(t, `"7"`, w.Header().Get("ETag"))
+		if want == http.StatusNotModified {
+			assert.Empty(t, w.Body.String())
+		}
+	}
+}
+
+func TestHandler_serviceErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	sqlErr := errors.New(`ERROR: relation "orders" does not exist (SQLSTATE 42P01)`)
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantDetail string
+	}{
+		{
+			name:       "not found",
+			err:        &service.Error{Kind: service.ErrNotFound, Message: "order not found", Err: gorm.ErrRecordNotFound},
+			wantStatus: http.StatusNotFound,
+			wantCode:   "not_found",
+			wantDetail: "order not found",
+		},
+		{
+			name:       "conflict",
+			err:        &service.Error{Kind: service.ErrConflict, Message: "order already exists", Err: sqlErr},
+			wantStatus: http.StatusConflict,
+			wantCode:   "conflict",
+			wantDetail: "order already exists",
+		},
+		{
+			name:       "database down",
+			err:        &service.Error{Kind: service.ErrUnavailable, Message: "database is unavailable", Err: sqlErr},
+			wantStatus: http.StatusServiceUnavailable,
+			wantCode:   "unavailable",
+			wantDetail: "database is unavailable",
+		},
+		{
+			name: "invalid patch",
+			err: &service.Error{Kind: service.ErrInvalidPatch, Message: "patched document is not a valid order",
+				Err: errors.New("json: cannot unmarshal string into Go struct field Order.items")},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "bad_request",
+			wantDetail: "patched document is not a valid order",
+		},
+		{
+			name:       "precondition failed",
+			err:        &service.Error{Kind: service.ErrPreconditionFailed, Message: "order revision does not match"},
+			wantStatus: http.StatusPreconditionFailed,
+			wantCode:   "precondition_failed",
+			wantDetail: "order revision does not match",
+		},
+		{
+			name:       "client gone",
+			err:        &service.Error{Kind: service.ErrCanceled, Message: "request canceled", Err: context.Canceled},
+			wantStatus: statusClientClosedRequest,
+			wantCode:   "client_closed_request",
+			wantDetail: "request canceled",
+		},
+		{
+			name:       "unclassified",
+			err:        sqlErr,
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   "internal_error",
+			wantDetail: "internal server error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			orders := mock_service.NewMockOrder(ctrl)
+			orders.EXPECT().GetByID("order123").Return(models.Order{}, tt.err)
+
+			h := NewHandler(&service.Service{Order: orders}, "")
+			w := httptest.NewRecorder()
+			h.InitRoutes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/order123", nil))
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+			assert.NotContains(t, w.Body.String(), "SQLSTATE")
+			assert.NotContains(t, w.Body.String(), "json:")
+
+			var body problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, problem{
+				Type:     "about:blank",
+				Title:    http.StatusText(tt.wantStatus),
+				Status:   tt.wantStatus,
+				Code:     tt.wantCode,
+				Detail:   tt.wantDetail,
+				Instance: "/api/orders/order123",
+			}, body)
+		})
+	}
+}
@@ -1,19 +1,126 @@
 package handler
 
 import (
+	"errors"
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"wb-task-L0/pkg/service"
+	"wb-task-L0/pkg/validator"
 )
 
-type errorResponse struct {
-	Message string `json:"message"`
+const problemContentType = "application/problem+json"
+
+// statusClientClosedRequest is nginx's non-standard status for requests the
+// client abandoned. Nobody reads the response, it only keeps them out of
+// the 5xx numbers.
+const statusClientClosedRequest = 499
+
+// problem is an RFC 7807 problem details body. Code is stable and meant for
+// clients to branch on, Detail is for humans and may change.
+type problem struct {
+	Type       string                `json:"type"`
+	Title      string                `json:"title"`
+	Status     int                   `json:"status"`
+	Code       string                `json:"code"`
+	Detail     string                `json:"detail,omitempty"`
+	Instance   string                `json:"instance,omitempty"`
+	Violations []validator.Violation `json:"violations,omitempty"`
+}
+
+// Stable error codes, one per response status.
+var problemCodes = map[int]string{
+	http.StatusBadRequest:           "bad_request",
+	http.StatusUnauthorized:         "unauthorized",
+	http.StatusForbidden:            "forbidden",
+	http.StatusNotFound:             "not_found",
+	http.StatusConflict:             "conflict",
+	http.StatusPreconditionFailed:   "precondition_failed",
+	http.StatusUnsupportedMediaType: "unsupported_media_type",
+	http.StatusUnprocessableEntity:  "validation_failed",
+	statusClientClosedRequest:       "client_closed_request",
+	http.StatusInternalServerError:  "internal_error",
+	http.StatusServiceUnavailable:   "unavailable",
 }
 
 type statusResponse struct {
 	Status string `json:"status"`
 }
 
+func abortWithProblem(c *gin.Context, p problem) {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	p.Code = problemCodes[p.Status]
+	p.Instance = c.Request.URL.Path
+
+	// gin keeps a Content-Type that is already set
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
+
+// newErrorResponse is for errors the handler detects itself, such as bad
+// params. message is sent to the client as is.
 func newErrorResponse(c *gin.Context, statusCode int, message string) {
 	logrus.Error(message)
-	c.AbortWithStatusJSON(statusCode, errorResponse{message})
+	abortWithProblem(c, problem{Status: statusCode, Detail: message})
+}
+
+func newValidationErrorResponse(c *gin.Context, err error) {
+	var violations validator.Errors
+	if !errors.As(err, &violations) {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	logrus.Warn(err.Error())
+	abortWithProblem(c, problem{
+		Status:     http.StatusUnprocessableEntity,
+		Detail:     "validation failed",
+		Violations: violations,
+	})
+}
+
+// newServiceErrorResponse maps errors returned by the services. Only
+// messages written for clients reach the body, causes (SQL included) are
+// only logged.
+func newServiceErrorResponse(c *gin.Context, err error) {
+	var (
+		classified *service.Error
+		violations validator.Errors
+	)
+	if errors.As(err, &violations) {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	p := problem{Status: http.StatusInternalServerError, Detail: "internal server error"}
+	if errors.As(err, &classified) {
+		p.Detail = classified.Message
+		switch {
+		case errors.Is(err, service.ErrNotFound):
+			p.Status = http.StatusNotFound
+		case errors.Is(err, service.ErrConflict):
+			p.Status = http.StatusConflict
+		case errors.Is(err, service.ErrValidation):
+			p.Status = http.StatusUnprocessableEntity
+		case errors.Is(err, service.ErrUnavailable):
+			p.Status = http.StatusServiceUnavailable
+		case errors.Is(err, service.ErrInvalidPatch):
+			p.Status = http.StatusBadRequest
+		case errors.Is(err, service.ErrPreconditionFailed):
+			p.Status = http.StatusPreconditionFailed
+		case errors.Is(err, service.ErrCanceled):
+			p.Status = statusClientClosedRequest
+		default:
+			p.Detail = "internal server error"
+		}
+	}
+
+	if p.Status >= http.StatusInternalServerError {
+		logrus.Error(err.Error())
+	} else {
+		logrus.Warn(err.Error())
+	}
+	abortWithProblem(c, p)
 }
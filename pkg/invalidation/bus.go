@@ -0,0 +1,216 @@
+package invalidation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+	"wb-task-L0/pkg/metrics"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Channel is the Postgres notification channel shared by all instances.
+const Channel = "order_cache_invalidation"
+
+const (
+	defaultMinReconnect = 10 * time.Second
+	defaultMaxReconnect = time.Minute
+	// pingInterval checks an idle listener connection, a dead one is only
+	// noticed on the next read otherwise.
+	pingInterval = 90 * time.Second
+)
+
+type Op string
+
+const (
+	// OpEvict drops the order, used after deletes.
+	OpEvict Op = "evict"
+	// OpRefresh reloads the order from the database, used after writes.
+	OpRefresh Op = "refresh"
+)
+
+type Message struct {
+	Op       Op     `json:"op"`
+	OrderUID string `json:"order_uid"`
+	Origin   string `json:"origin"`
+}
+
+// Publisher announces that a cached order is no longer current.
+type Publisher interface {
+	Publish(ctx context.Context, op Op, orderUID string) error
+}
+
+// Nop is used when invalidation is disabled.
+type Nop struct{}
+
+func (Nop) Publish(context.Context, Op, string) error { return nil }
+
+// Handler applies invalidations received from other instances.
+type Handler interface {
+	Evict(orderUID string)
+	Refresh(orderUID string)
+	// Resync is called after the listener reconnected, notifications sent
+	// while it was down are lost. It runs concurrently with Evict and
+	// Refresh, but never with itself.
+	Resync(ctx context.Context)
+}
+
+type Config struct {
+	// DSN of the listener connection, which lives outside the gorm pool.
+	DSN          string
+	MinReconnect time.Duration
+	MaxReconnect time.Duration
+}
+
+// Bus sends invalidations with pg_notify and receives them with LISTEN.
+// Every instance tags its messages with a random origin and ignores its
+// own, since the local cache is already updated by the writer.
+type Bus struct {
+	db     *gorm.DB
+	cfg    Config
+	origin string
+}
+
+func NewBus(db *gorm.DB, cfg Config) *Bus {
+	if cfg.MinReconnect <= 0 {
+		cfg.MinReconnect = defaultMinReconnect
+	}
+	if cfg.MaxReconnect < cfg.MinReconnect {
+		cfg.MaxReconnect = max(defaultMaxReconnect, cfg.MinReconnect)
+	}
+	return &Bus{db: db, cfg: cfg, origin: newOrigin()}
+}
+
+func newOrigin() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (b *Bus) Publish(ctx context.Context, op Op, orderUID string) error {
+	payload, err := json.Marshal(Message{Op: op, OrderUID: orderUID, Origin: b.origin})
+	if err != nil {
+		return err
+	}
+	if err := b.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", Channel, string(payload)).Error; err != nil {
+		metrics.CacheInvalidations.Add("errors", 1)
+		return err
+	}
+	metrics.CacheInvalidations.Add("sent", 1)
+	return nil
+}
+
+// Listen blocks until ctx is done, passing notifications from other
+// instances to h. The connection is re-established automatically.
+func (b *Bus) Listen(ctx context.Context, h Handler) error {
+	listener := pq.NewListener(b.cfg.DSN, b.cfg.MinReconnect, b.cfg.MaxReconnect, logEvent)
+	defer listener.Close()
+
+	if err := listener.Listen(Channel); err != nil {
+		return err
+	}
+	log.Printf("invalidation: listening on %s", Channel)
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	var resync resyncer
+	defer resync.wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			if n == nil {
+				// pq sends nil after a reconnect
+				log.Println("invalidation: listener reconnected, resyncing cache")
+				metrics.CacheInvalidations.Add("resyncs", 1)
+				resync.request(ctx, h)
+				continue
+			}
+			b.dispatch(n.Extra, h)
+		case <-ticker.C:
+			go func() {
+				if err := listener.Ping(); err != nil {
+					log.Printf("invalidation: listener ping failed: %v", err)
+				}
+			}()
+		}
+	}
+}
+
+// resyncer runs Handler.Resync beside the notify loop, which has to keep
+// applying invalidations while the cache is rebuilt. Reconnects during a
+// resync are merged into one rerun after it, that rerun covers them all.
+type resyncer struct {
+	mu      sync.Mutex
+	running bool
+	rerun   bool
+	done    sync.WaitGroup
+}
+
+func (r *resyncer) request(ctx context.Context, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running {
+		r.rerun = true
+		return
+	}
+	r.running = true
+	r.done.Add(1)
+	go r.run(ctx, h)
+}
+
+func (r *resyncer) run(ctx context.Context, h Handler) {
+	defer r.done.Done()
+	for {
+		h.Resync(ctx)
+
+		r.mu.Lock()
+		if !r.rerun || ctx.Err() != nil {
+			r.running, r.rerun = false, false
+			r.mu.Unlock()
+			return
+		}
+		r.rerun = false
+		r.mu.Unlock()
+	}
+}
+
+// wait blocks until the resync in flight, if any, has returned.
+func (r *resyncer) wait() {
+	r.done.Wait()
+}
+
+func (b *Bus) dispatch(payload string, h Handler) {
+	var m Message
+	if err := json.Unmarshal([]byte(payload), &m); err != nil {
+		log.Printf("invalidation: bad payload %q: %v", payload, err)
+		return
+	}
+	if m.Origin == b.origin {
+		return
+	}
+	metrics.CacheInvalidations.Add("received", 1)
+
+	switch m.Op {
+	case OpEvict:
+		h.Evict(m.OrderUID)
+	case OpRefresh:
+		h.Refresh(m.OrderUID)
+	default:
+		log.Printf("invalidation: unknown op %q for order %s", m.Op, m.OrderUID)
+	}
+}
+
+func logEvent(ev pq.ListenerEventType, err error) {
+	if err != nil {
+		log.Printf("invalidation: listener event %d: %v", ev, err)
+	}
+}
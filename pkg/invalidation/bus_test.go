@@ -0,0 +1,117 @@
+package invalidation
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+	"wb-task-L0/pkg/cache"
+	"wb-task-L0/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type recorder struct {
+	evicted   []string
+	refreshed []string
+	resyncs   int
+}
+
+func (r *recorder) Evict(id string)        { r.evicted = append(r.evicted, id) }
+func (r *recorder) Refresh(id string)      { r.refreshed = append(r.refreshed, id) }
+func (r *recorder) Resync(context.Context) { r.resyncs++ }
+
+func TestBus_Dispatch(t *testing.T) {
+	b := &Bus{origin: "self"}
+	r := &recorder{}
+
+	b.dispatch(`{"op":"evict","order_uid":"a","origin":"peer"}`, r)
+	b.dispatch(`{"op":"refresh","order_uid":"b","origin":"peer"}`, r)
+	b.dispatch(`{"op":"evict","order_uid":"c","origin":"self"}`, r)
+	b.dispatch(`{"op":"unknown","order_uid":"d","origin":"peer"}`, r)
+	b.dispatch(`not json`, r)
+
+	assert.Equal(t, []string{"a"}, r.evicted)
+	assert.Equal(t, []string{"b"}, r.refreshed)
+}
+
+// blockingResync counts resyncs, each one waits for release.
+type blockingResync struct {
+	recorder
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (r *blockingResync) Resync(context.Context) {
+	r.calls.Add(1)
+	<-r.release
+}
+
+func TestResyncer_MergesRequests(t *testing.T) {
+	h := &blockingResync{release: make(chan struct{})}
+	var r resyncer
+
+	r.request(context.Background(), h)
+	require.Eventually(t, func() bool { return h.calls.Load() == 1 }, time.Second, time.Millisecond)
+	// these return right away, the notify loop is not held up
+	r.request(context.Background(), h)
+	r.request(context.Background(), h)
+
+	close(h.release)
+	r.wait()
+	assert.Equal(t, int32(2), h.calls.Load(), "requests during a resync are merged into one rerun")
+
+	r.request(context.Background(), h)
+	r.wait()
+	assert.Equal(t, int32(3), h.calls.Load(), "a later request starts a new resync")
+}
+
+func TestCacheHandler(t *testing.T) {
+	c := cache.NewCache(cache.Config{})
+	c.Set(models.Order{OrderUID: "evicted"})
+	c.Set(models.Order{OrderUID: "changed", TrackNumber: "old"})
+	c.Set(models.Order{OrderUID: "gone"})
+	c.Set(models.Order{OrderUID: "broken"})
+
+	load := func(id string) (models.Order, error) {
+		switch id {
+		case "changed", "uncached":
+			return models.Order{OrderUID: id, TrackNumber: "new"}, nil
+		case "gone":
+			return models.Order{}, gorm.ErrRecordNotFound
+		default:
+			return models.Order{}, errors.New("connection refused")
+		}
+	}
+	page := func(_ context.Context, after *models.Order, _ time.Time, _ int) ([]models.Order, error) {
+		if after != nil {
+			return nil, nil
+		}
+		return []models.Order{{OrderUID: "x"}, {OrderUID: "y"}}, nil
+	}
+	warmer := cache.NewWarmer(c, cache.WarmupConfig{BatchSize: 2}, page, nil)
+	h := NewCacheHandler(c, load, warmer)
+
+	h.Evict("evicted")
+	h.Refresh("changed")
+	h.Refresh("gone")
+	h.Refresh("broken")
+	h.Refresh("uncached")
+
+	_, ok := c.Get("evicted")
+	assert.False(t, ok)
+	got, ok := c.Get("changed")
+	assert.True(t, ok)
+	assert.Equal(t, "new", got.TrackNumber)
+	_, ok = c.Get("gone")
+	assert.False(t, ok)
+	_, ok = c.Get("broken")
+	assert.False(t, ok, "entries that failed to reload must not be served")
+	assert.False(t, c.Contains("uncached"), "orders this instance doesn't cache are not loaded")
+
+	h.Resync(context.Background())
+	assert.Equal(t, 2, c.Len(), "resync replaces the whole cache")
+}
@@ -0,0 +1,54 @@
+package invalidation
+
+import (
+	"context"
+	"errors"
+	"log"
+	"wb-task-L0/pkg/cache"
+
+	"gorm.io/gorm"
+)
+
+// CacheHandler applies invalidations to the local order cache.
+type CacheHandler struct {
+	cache  cache.Cache
+	load   cache.Loader
+	warmer *cache.Warmer
+}
+
+func NewCacheHandler(c cache.Cache, load cache.Loader, warmer *cache.Warmer) *CacheHandler {
+	return &CacheHandler{cache: c, load: load, warmer: warmer}
+}
+
+func (h *CacheHandler) Evict(orderUID string) {
+	h.cache.Delete(orderUID)
+}
+
+// Refresh reloads the order if this instance caches it, orders it doesn't
+// hold are left to be loaded on demand. If the reload fails the entry is
+// dropped, so the next read goes to the database instead of returning the
+// outdated version.
+func (h *CacheHandler) Refresh(orderUID string) {
+	if !h.cache.Contains(orderUID) {
+		return
+	}
+	order, err := h.load(orderUID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("invalidation: failed to reload order %s: %v", orderUID, err)
+		}
+		h.cache.Delete(orderUID)
+		return
+	}
+	h.cache.SetIfNewer(order)
+}
+
+// Resync rebuilds the cache with the configured warm-up, the old contents
+// are served until the new ones are complete.
+func (h *CacheHandler) Resync(ctx context.Context) {
+	if err := h.warmer.Rebuild(ctx); err != nil {
+		log.Printf("invalidation: resync failed: %v", err)
+		return
+	}
+	log.Printf("invalidation: cache resynced with %d orders", h.cache.Len())
+}
@@ -0,0 +1,138 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+	"wb-task-L0/pkg/models"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const defaultBatchTimeout = 500 * time.Millisecond
+
+// startBatch accumulates up to batchSize messages, or whatever arrived within
+// batchTimeout of the first one, and writes them in a single transaction.
+func (c *Consumer) startBatch(ctx context.Context) {
+	log.Printf("Kafka consumer started in batch mode (size %d, timeout %s)...", c.batchSize, c.batchTimeout)
+
+	batch := make([]kafka.Message, 0, c.batchSize)
+	var flushAt time.Time
+	fetchFailures := 0
+
+	for {
+		fetchCtx, cancel := ctx, context.CancelFunc(func() {})
+		if len(batch) > 0 {
+			fetchCtx, cancel = context.WithDeadline(ctx, flushAt)
+		}
+		m, err := c.reader.FetchMessage(fetchCtx)
+		cancel()
+
+		switch {
+		case ctx.Err() != nil:
+			log.Println("Kafka consumer stopped by context")
+			return
+		case errors.Is(err, context.DeadlineExceeded):
+			c.flushBatch(ctx, batch)
+			batch = batch[:0]
+			continue
+		case err != nil:
+			fetchFailures++
+			log.Printf("error reading message: %v", err)
+			c.waitAfterFetchError(ctx, fetchFailures)
+			continue
+		}
+		fetchFailures = 0
+
+		if len(batch) == 0 {
+			flushAt = time.Now().Add(c.batchTimeout)
+		}
+		batch = append(batch, m)
+		if len(batch) >= c.batchSize {
+			c.flushBatch(ctx, batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flushBatch writes consecutive orders of the batch at once. Any other
+// message splits the batch, so that an order and its later delete keep their
+// order. If a batch write fails, its messages go through the regular
+// per-order path, so one bad order only affects itself.
+//
+// Only the handled prefix of msgs is committed. process gives up only on
+// shutdown, the rest of the batch is then redelivered.
+func (c *Consumer) flushBatch(ctx context.Context, msgs []kafka.Message) {
+	handled := c.applyBatch(ctx, msgs)
+	if handled < len(msgs) {
+		log.Printf("stopped after %d of %d messages of the batch, the rest will be redelivered", handled, len(msgs))
+	}
+	if handled == 0 {
+		return
+	}
+
+	commitCtx := ctx
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		commitCtx, cancel = context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+	}
+	c.commit(commitCtx, msgs[:handled])
+}
+
+// applyBatch returns how many leading messages of msgs were handled.
+func (c *Consumer) applyBatch(ctx context.Context, msgs []kafka.Message) int {
+	var (
+		orders []*models.Order
+		first  int // index of the first message behind orders
+	)
+	for i, m := range msgs {
+		ev, err := c.decodeEvent(m)
+		if err == nil && ev.kind == eventUpsert {
+			if len(orders) == 0 {
+				first = i
+			}
+			order := ev.order
+			orders = append(orders, &order)
+			continue
+		}
+
+		if n := c.writeBatch(ctx, orders, msgs[first:i]); n < len(orders) {
+			return first + n
+		}
+		orders = nil
+		if !c.process(ctx, m) {
+			return i
+		}
+	}
+	if len(orders) == 0 {
+		return len(msgs)
+	}
+	return first + c.writeBatch(ctx, orders, msgs[first:])
+}
+
+// writeBatch returns how many of msgs, the messages behind orders, were
+// handled.
+func (c *Consumer) writeBatch(ctx context.Context, orders []*models.Order, msgs []kafka.Message) int {
+	if len(orders) == 0 {
+		return 0
+	}
+
+	results, err := c.orderRepo.UpsertOrdersBatch(ctx, orders)
+	if err == nil {
+		for i, o := range orders {
+			c.recordUpsert(o, results[i])
+		}
+		log.Printf("batch of %d orders saved successfully", len(orders))
+		return len(msgs)
+	}
+
+	log.Printf("batch of %d orders failed, falling back to per-order writes: %v", len(orders), err)
+	for i, m := range msgs {
+		if !c.process(ctx, m) {
+			return i
+		}
+	}
+	return len(msgs)
+}
@@ -0,0 +1,100 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"wb-task-L0/pkg/cache"
+	"wb-task-L0/pkg/invalidation"
+	"wb-task-L0/pkg/models"
+	"wb-task-L0/pkg/repository"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchRepo fails every batch write, so orders go through the per-order
+// path, and calls onUpsert before each of those writes.
+type batchRepo struct {
+	repository.Order
+	onUpsert func(order *models.Order) error
+	saved    []string
+}
+
+func (r *batchRepo) UpsertOrdersBatch(context.Context, []*models.Order) ([]repository.UpsertResult, error) {
+	return nil, errors.New("batch insert failed")
+}
+
+func (r *batchRepo) UpsertOrderWithAssociations(_ context.Context, order *models.Order) (repository.UpsertResult, error) {
+	if err := r.onUpsert(order); err != nil {
+		return 0, err
+	}
+	r.saved = append(r.saved, order.OrderUID)
+	return repository.UpsertCreated, nil
+}
+
+func orderMessage(t *testing.T, offset int64, uid string) kafka.Message {
+	data, err := os.ReadFile("../../order.json")
+	require.NoError(t, err)
+	var order map[string]any
+	require.NoError(t, json.Unmarshal(data, &order))
+	order["order_uid"] = uid
+	order["delivery"].(map[string]any)["order_uid"] = uid
+	order["payment"].(map[string]any)["order_uid"] = uid
+	for _, item := range order["items"].([]any) {
+		item.(map[string]any)["order_uid"] = uid
+	}
+	value, err := json.Marshal(order)
+	require.NoError(t, err)
+	return kafka.Message{Offset: offset, Key: []byte(uid), Value: value}
+}
+
+func TestConsumer_applyBatch_StopsAtUnhandledMessage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repo := &batchRepo{onUpsert: func(order *models.Order) error {
+		if order.OrderUID == "c" {
+			// shutdown while c is being written
+			cancel()
+			return context.Canceled
+		}
+		return nil
+	}}
+	c := &Consumer{
+		codecs:        testConsumer.codecs,
+		retry:         DefaultRetryPolicy(),
+		orderRepo:     repo,
+		cache:         cache.NewCache(cache.Config{}),
+		invalidations: invalidation.Nop{},
+	}
+
+	msgs := []kafka.Message{
+		orderMessage(t, 1, "a"),
+		orderMessage(t, 2, "b"),
+		{Offset: 3}, // empty, skipped
+		orderMessage(t, 4, "c"),
+		orderMessage(t, 5, "d"),
+	}
+
+	assert.Equal(t, 3, c.applyBatch(ctx, msgs), "only a, b and the empty message may be committed")
+	assert.Equal(t, []string{"a", "b"}, repo.saved)
+}
+
+func TestConsumer_applyBatch_HandlesAll(t *testing.T) {
+	repo := &batchRepo{onUpsert: func(*models.Order) error { return nil }}
+	c := &Consumer{
+		codecs:        testConsumer.codecs,
+		retry:         DefaultRetryPolicy(),
+		orderRepo:     repo,
+		cache:         cache.NewCache(cache.Config{}),
+		invalidations: invalidation.Nop{},
+	}
+
+	msgs := []kafka.Message{orderMessage(t, 1, "a"), {Offset: 2}, orderMessage(t, 3, "b")}
+	assert.Equal(t, 3, c.applyBatch(context.Background(), msgs))
+	assert.Equal(t, []string{"a", "b"}, repo.saved)
+}
@@ -0,0 +1,65 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	HeaderDLQReason     = "x-dlq-reason"
+	HeaderDLQTopic      = "x-dlq-source-topic"
+	HeaderDLQPartition  = "x-dlq-source-partition"
+	HeaderDLQOffset     = "x-dlq-source-offset"
+	HeaderDLQAttempts   = "x-dlq-attempts"
+	HeaderDLQRejectedAt = "x-dlq-rejected-at"
+)
+
+// messageWriter is the part of kafka.Writer the dead-letter writer uses.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// DeadLetterWriter republishes messages the consumer could not process to a
+// separate topic, keeping the original key, payload and headers intact.
+type DeadLetterWriter struct {
+	writer messageWriter
+}
+
+func NewDeadLetterWriter(brokers []string, topic string) *DeadLetterWriter {
+	return &DeadLetterWriter{
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(brokers...),
+			Topic:                  topic,
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+		},
+	}
+}
+
+func (w *DeadLetterWriter) Publish(ctx context.Context, m kafka.Message, reason error, attempts int) error {
+	headers := make([]kafka.Header, 0, len(m.Headers)+6)
+	headers = append(headers, m.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderDLQReason, Value: []byte(reason.Error())},
+		kafka.Header{Key: HeaderDLQTopic, Value: []byte(m.Topic)},
+		kafka.Header{Key: HeaderDLQPartition, Value: []byte(strconv.Itoa(m.Partition))},
+		kafka.Header{Key: HeaderDLQOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+		kafka.Header{Key: HeaderDLQAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: HeaderDLQRejectedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+	)
+
+	return w.writer.WriteMessages(ctx, kafka.Message{
+		Key:     m.Key,
+		Value:   m.Value,
+		Headers: headers,
+	})
+}
+
+func (w *DeadLetterWriter) Close() error {
+	return w.writer.Close()
+}
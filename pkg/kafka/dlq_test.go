@@ -0,0 +1,150 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"wb-task-L0/pkg/metrics"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWriter fails the first failures writes and records the rest.
+type fakeWriter struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	written  []kafka.Message
+}
+
+func (w *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.calls++
+	if w.calls <= w.failures {
+		return errors.New("broker unavailable")
+	}
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+func headerMap(headers []kafka.Header) map[string]string {
+	m := make(map[string]string, len(headers))
+	for _, h := range headers {
+		m[h.Key] = string(h.Value)
+	}
+	return m
+}
+
+func TestDeadLetterWriter_Publish(t *testing.T) {
+	w := &fakeWriter{}
+	dlq := &DeadLetterWriter{writer: w}
+	m := kafka.Message{
+		Topic:     "orders",
+		Partition: 2,
+		Offset:    41,
+		Key:       []byte("order1"),
+		Value:     []byte(`{"order_uid":`),
+		Headers:   []kafka.Header{{Key: "content-type", Value: []byte("application/json")}},
+	}
+
+	require.NoError(t, dlq.Publish(context.Background(), m, errors.New("unexpected EOF"), 3))
+	require.Len(t, w.written, 1)
+
+	out := w.written[0]
+	assert.Equal(t, m.Key, out.Key)
+	assert.Equal(t, m.Value, out.Value)
+	assert.Empty(t, out.Topic, "the writer's topic is used")
+
+	headers := headerMap(out.Headers)
+	assert.Equal(t, "application/json", headers["content-type"], "original headers are kept")
+	assert.Equal(t, "unexpected EOF", headers[HeaderDLQReason])
+	assert.Equal(t, "orders", headers[HeaderDLQTopic])
+	assert.Equal(t, "2", headers[HeaderDLQPartition])
+	assert.Equal(t, "41", headers[HeaderDLQOffset])
+	assert.Equal(t, "3", headers[HeaderDLQAttempts])
+	rejectedAt, err := time.Parse(time.RFC3339, headers[HeaderDLQRejectedAt])
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), rejectedAt, time.Minute)
+}
+
+func TestConsumer_reject(t *testing.T) {
+	retry := RetryPolicy{MaxAttempts: 1, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	m := kafka.Message{Partition: 0, Offset: 7}
+	reason := permanent(errors.New("bad payload"))
+
+	t.Run("retries the publish until it succeeds", func(t *testing.T) {
+		w := &fakeWriter{failures: 3}
+		c := &Consumer{deadLetter: &DeadLetterWriter{writer: w}, retry: retry}
+
+		require.NoError(t, c.reject(context.Background(), m, reason, 1))
+		assert.Equal(t, 4, w.calls)
+		assert.Len(t, w.written, 1)
+	})
+
+	t.Run("gives up only when the context is done", func(t *testing.T) {
+		w := &fakeWriter{failures: 1 << 30}
+		c := &Consumer{deadLetter: &DeadLetterWriter{writer: w}, retry: retry}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		assert.ErrorIs(t, c.reject(ctx, m, reason, 1), context.DeadlineExceeded)
+		assert.Greater(t, w.calls, 1)
+		assert.Empty(t, w.written)
+	})
+
+	t.Run("counts drops without a topic", func(t *testing.T) {
+		before := droppedCount()
+		c := &Consumer{retry: retry}
+
+		require.NoError(t, c.reject(context.Background(), m, reason, 1))
+		assert.Equal(t, before+1, droppedCount())
+	})
+}
+
+func droppedCount() int64 {
+	v := metrics.KafkaRejected.Get("dropped")
+	if v == nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(v.String(), 10, 64)
+	return n
+}
+
+// A worker must not move on while its message is neither handled nor
+// dead-lettered, or the offset tracker can never commit past it.
+func TestConsumer_process_ResolvesEveryMessage(t *testing.T) {
+	retry := RetryPolicy{MaxAttempts: 1, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	w := &fakeWriter{failures: 2}
+	c := &Consumer{codecs: testConsumer.codecs, deadLetter: &DeadLetterWriter{writer: w}, retry: retry}
+
+	tracker := newOffsetTracker()
+	msgs := []kafka.Message{
+		{Partition: 0, Offset: 1, Value: []byte("{")},
+		{Partition: 0, Offset: 2, Value: []byte("{")},
+	}
+	for _, m := range msgs {
+		tracker.track(m)
+	}
+	for _, m := range msgs {
+		require.True(t, c.process(context.Background(), m))
+		tracker.markDone(m)
+	}
+
+	committable := tracker.committable()
+	require.Len(t, committable, 1)
+	assert.Equal(t, int64(2), committable[0].Offset)
+	assert.Len(t, w.written, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w.failures = 1 << 30
+	assert.False(t, c.process(ctx, kafka.Message{Offset: 3, Value: []byte("{")}), "gives up only on shutdown")
+}
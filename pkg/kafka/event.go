@@ -0,0 +1,108 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"wb-task-L0/pkg/codec"
+	"wb-task-L0/pkg/models"
+	"wb-task-L0/pkg/validator"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const EventOrderDeleted = "order.deleted"
+
+type eventKind int
+
+const (
+	eventSkip eventKind = iota
+	eventUpsert
+	eventDelete
+)
+
+type orderEvent struct {
+	kind     eventKind
+	orderUID string
+	order    models.Order
+	// version and eventTime of a delete, an upsert carries them in order
+	version   int64
+	eventTime time.Time
+}
+
+// envelope is the explicit JSON event wrapper, e.g.
+// {"type":"order.deleted","order_uid":"b563feb7b2b84b6test","version":4}.
+// Plain order payloads have no type. Binary codecs use tombstones only.
+// The version of a delete is optional, like that of an order.
+type envelope struct {
+	Type     string `json:"type"`
+	OrderUID string `json:"order_uid"`
+	Version  int64  `json:"version"`
+}
+
+// decodeEvent turns a message into an upsert or a delete. A keyed message
+// with an empty value is a tombstone and deletes the order with that key.
+// The payload codec comes from the content-type header or the default.
+func (c *Consumer) decodeEvent(m kafka.Message) (orderEvent, error) {
+	if len(m.Value) == 0 {
+		if len(m.Key) == 0 {
+			return orderEvent{kind: eventSkip}, nil
+		}
+		return orderEvent{kind: eventDelete, orderUID: string(m.Key), eventTime: m.Time}, nil
+	}
+
+	cd, err := c.codecs.Resolve(header(m, codec.HeaderContentType))
+	if err != nil {
+		return orderEvent{}, permanent(err)
+	}
+	if cd.Name() != codec.JSON {
+		return decodeOrderEvent(cd, m)
+	}
+
+	var env envelope
+	if err := json.Unmarshal(m.Value, &env); err != nil {
+		return orderEvent{}, permanent(fmt.Errorf("cannot unmarshal: %w", err))
+	}
+
+	switch env.Type {
+	case "":
+	case EventOrderDeleted:
+		uid := env.OrderUID
+		if uid == "" {
+			uid = string(m.Key)
+		}
+		if uid == "" {
+			return orderEvent{}, permanent(errors.New("delete event without order_uid"))
+		}
+		return orderEvent{kind: eventDelete, orderUID: uid, version: env.Version, eventTime: m.Time}, nil
+	default:
+		return orderEvent{}, permanent(fmt.Errorf("unknown event type %q", env.Type))
+	}
+
+	return decodeOrderEvent(cd, m)
+}
+
+func decodeOrderEvent(cd codec.Codec, m kafka.Message) (orderEvent, error) {
+	order, err := cd.Decode(m.Value)
+	if err != nil {
+		return orderEvent{}, permanent(fmt.Errorf("cannot decode %s payload: %w", cd.Name(), err))
+	}
+	if err := validator.ValidateOrder(&order); err != nil {
+		return orderEvent{}, permanent(err)
+	}
+	if order.EventTime.IsZero() {
+		order.EventTime = m.Time
+	}
+	return orderEvent{kind: eventUpsert, orderUID: order.OrderUID, order: order}, nil
+}
+
+func header(m kafka.Message, key string) string {
+	for _, h := range m.Headers {
+		if strings.EqualFold(h.Key, key) {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+	"wb-task-L0/pkg/codec"
+	"wb-task-L0/pkg/models"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testConsumer = &Consumer{codecs: codec.NewJSONRegistry()}
+
+func TestDecodeEvent_Deletes(t *testing.T) {
+	ev, err := testConsumer.decodeEvent(kafka.Message{Key: []byte("order1")})
+	require.NoError(t, err)
+	assert.Equal(t, orderEvent{kind: eventDelete, orderUID: "order1"}, ev)
+
+	ev, err = testConsumer.decodeEvent(kafka.Message{Value: []byte(`{"type":"order.deleted","order_uid":"order2"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, orderEvent{kind: eventDelete, orderUID: "order2"}, ev)
+
+	ev, err = testConsumer.decodeEvent(kafka.Message{Key: []byte("order3"), Value: []byte(`{"type":"order.deleted"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, "order3", ev.orderUID)
+
+	at := time.Now()
+	ev, err = testConsumer.decodeEvent(kafka.Message{Time: at, Value: []byte(`{"type":"order.deleted","order_uid":"order4","version":7}`)})
+	require.NoError(t, err)
+	assert.Equal(t, orderEvent{kind: eventDelete, orderUID: "order4", version: 7, eventTime: at}, ev, "deletes carry their version and time")
+
+	ev, err = testConsumer.decodeEvent(kafka.Message{})
+	require.NoError(t, err)
+	assert.Equal(t, eventSkip, ev.kind)
+}
+
+func TestDecodeEvent_Rejects(t *testing.T) {
+	for _, value := range []string{
+		`{"type":"order.deleted"}`,
+		`{"type":"order.archived","order_uid":"order1"}`,
+		`not json`,
+		`{"order_uid":"order1"}`,
+	} {
+		_, err := testConsumer.decodeEvent(kafka.Message{Value: []byte(value)})
+		assert.Error(t, err, value)
+		assert.False(t, isTransient(err), value)
+	}
+}
+
+func TestDecodeEvent_ContentTypeHeader(t *testing.T) {
+	codecs, err := codec.NewRegistry(codec.JSON, "../../schemas")
+	require.NoError(t, err)
+	c := &Consumer{codecs: codecs}
+
+	order := models.Order{OrderUID: "order1", TrackNumber: "track1"}
+	pb, err := codecs.Resolve("application/x-protobuf")
+	require.NoError(t, err)
+	data, err := pb.Encode(&order)
+	require.NoError(t, err)
+
+	_, err = c.decodeEvent(kafka.Message{
+		Value:   data,
+		Headers: []kafka.Header{{Key: "Content-Type", Value: []byte("application/x-protobuf")}},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "validation failed", "payload is decoded as protobuf and then validated")
+
+	_, err = c.decodeEvent(kafka.Message{
+		Value:   data,
+		Headers: []kafka.Header{{Key: "content-type", Value: []byte("text/xml")}},
+	})
+	assert.ErrorContains(t, err, "unsupported content type")
+}
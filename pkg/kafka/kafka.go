@@ -2,38 +2,106 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"log"
+	"time"
 	"wb-task-L0/pkg/cache"
+	"wb-task-L0/pkg/codec"
+	"wb-task-L0/pkg/invalidation"
+	"wb-task-L0/pkg/metrics"
 	"wb-task-L0/pkg/models"
 	"wb-task-L0/pkg/repository"
 
 	"github.com/segmentio/kafka-go"
 )
 
+type Config struct {
+	Brokers []string
+	Topic   string
+	GroupID string
+	// DLQTopic receives messages that failed permanently or ran out of
+	// retries. Without it transient failures are retried forever and
+	// permanent ones are dropped, see Consumer.reject.
+	DLQTopic string
+	Retry    RetryPolicy
+	// Codecs decodes payloads; JSON only when nil.
+	Codecs *codec.Registry
+	// Invalidations notifies other instances about applied writes.
+	Invalidations invalidation.Publisher
+
+	// Workers > 1 enables parallel processing with per-key ordering.
+	Workers   int
+	QueueSize int
+
+	// BatchSize > 1 enables batch mode, which takes precedence over Workers.
+	BatchSize    int
+	BatchTimeout time.Duration
+}
+
 type Consumer struct {
-	reader    *kafka.Reader
-	orderRepo repository.Order
-	cache     *cache.OrderCache
+	reader        *kafka.Reader
+	deadLetter    *DeadLetterWriter
+	retry         RetryPolicy
+	codecs        *codec.Registry
+	workers       int
+	queueSize     int
+	batchSize     int
+	batchTimeout  time.Duration
+	orderRepo     repository.Order
+	cache         cache.Cache
+	invalidations invalidation.Publisher
 }
 
-func NewConsumer(brokers []string, topic, groupID string, repo repository.Order, cache *cache.OrderCache) *Consumer {
+func NewConsumer(cfg Config, repo repository.Order, cache cache.Cache) *Consumer {
 	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: brokers,
-		Topic:   topic,
-		GroupID: groupID,
+		Brokers: cfg.Brokers,
+		Topic:   cfg.Topic,
+		GroupID: cfg.GroupID,
 	})
 
+	var deadLetter *DeadLetterWriter
+	if cfg.DLQTopic != "" {
+		deadLetter = NewDeadLetterWriter(cfg.Brokers, cfg.DLQTopic)
+	}
+
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+
+	batchTimeout := cfg.BatchTimeout
+	if batchTimeout <= 0 {
+		batchTimeout = defaultBatchTimeout
+	}
+
 	return &Consumer{
-		reader:    reader,
-		orderRepo: repo,
-		cache:     cache,
+		reader:        reader,
+		deadLetter:    deadLetter,
+		retry:         cfg.Retry.withDefaults(),
+		codecs:        codecsOrDefault(cfg.Codecs),
+		workers:       cfg.Workers,
+		queueSize:     queueSize,
+		batchSize:     cfg.BatchSize,
+		batchTimeout:  batchTimeout,
+		orderRepo:     repo,
+		cache:         cache,
+		invalidations: invalidationsOrNop(cfg.Invalidations),
 	}
 }
 
 func (c *Consumer) Start(ctx context.Context) {
+	if c.batchSize > 1 {
+		c.startBatch(ctx)
+		return
+	}
+	if c.workers > 1 {
+		c.startPool(ctx)
+		return
+	}
+
 	log.Println("Kafka consumer started...")
 
+	fetchFailures := 0
 	for {
 		select {
 		case <-ctx.Done():
@@ -42,31 +110,21 @@ func (c *Consumer) Start(ctx context.Context) {
 		default:
 			m, err := c.reader.FetchMessage(ctx)
 			if err != nil {
-				log.Printf("error reading message: %v", err)
+				if ctx.Err() == nil {
+					fetchFailures++
+					log.Printf("error reading message: %v", err)
+					c.waitAfterFetchError(ctx, fetchFailures)
+				}
 				continue
 			}
+			fetchFailures = 0
 
-			raw := string(m.Value)
-			if raw == "" {
-				log.Println("empty message, skipping")
-				c.reader.CommitMessages(ctx, m)
+			if !c.process(ctx, m) {
+				// only on shutdown: the message stays uncommitted and is
+				// redelivered
 				continue
 			}
 
-			var order models.Order
-			if err := json.Unmarshal(m.Value, &order); err != nil {
-				log.Printf("invalid message, cannot unmarshal: %v", err)
-				c.reader.CommitMessages(ctx, m)
-				continue
-			}
-
-			if err := c.orderRepo.CreateOrderWithAssociations(ctx, &order); err != nil {
-				log.Printf("failed to save order in DB: %v", err)
-			} else {
-				c.cache.Set(order)
-				log.Printf("order saved successfully: %s", order.OrderUID)
-			}
-
 			if err := c.reader.CommitMessages(ctx, m); err != nil {
 				log.Printf("failed to commit message: %v", err)
 			}
@@ -74,6 +132,159 @@ func (c *Consumer) Start(ctx context.Context) {
 	}
 }
 
+// process runs a message through decoding, persistence and dead-letter
+// routing and blocks until the message is handled or rejected. It reports
+// false only when ctx was cancelled first; the offset must then not be
+// committed, and nothing after it either.
+func (c *Consumer) process(ctx context.Context, m kafka.Message) bool {
+	attempts, err := c.processWithRetry(ctx, m)
+	if err == nil {
+		return true
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+
+	log.Printf("message rejected after %d attempt(s) (partition %d, offset %d): %v", attempts, m.Partition, m.Offset, err)
+	return c.reject(ctx, m, err, attempts) == nil
+}
+
+// waitAfterFetchError backs off after consecutive FetchMessage errors, so a
+// broker outage doesn't turn the fetch loop into a busy spin.
+func (c *Consumer) waitAfterFetchError(ctx context.Context, failures int) {
+	_ = sleepCtx(ctx, c.retry.backoff(failures))
+}
+
+func codecsOrDefault(r *codec.Registry) *codec.Registry {
+	if r == nil {
+		return codec.NewJSONRegistry()
+	}
+	return r
+}
+
+// processWithRetry handles a message, retrying transient failures with
+// exponential backoff. When no dead-letter topic is configured, transient
+// failures are retried until they succeed or ctx is cancelled, since there
+// would be nowhere to route the message to.
+func (c *Consumer) processWithRetry(ctx context.Context, m kafka.Message) (int, error) {
+	attempt := 1
+	for {
+		err := c.handleMessage(ctx, m)
+		if err == nil || !isTransient(err) {
+			return attempt, err
+		}
+		if attempt >= c.retry.MaxAttempts && c.deadLetter != nil {
+			return attempt, err
+		}
+
+		delay := c.retry.backoff(attempt)
+		log.Printf("transient error on attempt %d (partition %d, offset %d), retrying in %s: %v",
+			attempt, m.Partition, m.Offset, delay, err)
+		if err := sleepCtx(ctx, delay); err != nil {
+			return attempt, err
+		}
+		attempt++
+	}
+}
+
+func (c *Consumer) handleMessage(ctx context.Context, m kafka.Message) error {
+	ev, err := c.decodeEvent(m)
+	if err != nil {
+		return err
+	}
+
+	switch ev.kind {
+	case eventSkip:
+		log.Println("empty message, skipping")
+		return nil
+	case eventDelete:
+		return c.deleteOrder(ctx, ev)
+	}
+
+	order := ev.order
+	result, err := c.orderRepo.UpsertOrderWithAssociations(ctx, &order)
+	if err != nil {
+		return fmt.Errorf("failed to save order in DB: %w", err)
+	}
+	c.recordUpsert(&order, result)
+	return nil
+}
+
+func (c *Consumer) deleteOrder(ctx context.Context, ev orderEvent) error {
+	orderUID := ev.orderUID
+	if err := c.orderRepo.DeleteVersion(ctx, orderUID, ev.version, ev.eventTime); err != nil {
+		return fmt.Errorf("failed to delete order from DB: %w", err)
+	}
+	c.cache.Delete(orderUID)
+	c.invalidate(invalidation.OpEvict, orderUID)
+	metrics.OrderDeletes.Add(1)
+	log.Printf("order %s deleted successfully", orderUID)
+	return nil
+}
+
+func (c *Consumer) recordUpsert(order *models.Order, result repository.UpsertResult) {
+	metrics.OrderUpserts.Add(result.String(), 1)
+
+	if result == repository.UpsertIgnored {
+		log.Printf("order %s ignored: stored version is newer or equal (version %d, event time %s)",
+			order.OrderUID, order.EventVersion, order.EventTime.Format(time.RFC3339))
+		return
+	}
+
+	c.cache.Set(*order)
+	c.invalidate(invalidation.OpRefresh, order.OrderUID)
+	log.Printf("order %s %s successfully", order.OrderUID, result)
+}
+
+func (c *Consumer) invalidate(op invalidation.Op, orderUID string) {
+	if err := c.invalidations.Publish(context.Background(), op, orderUID); err != nil {
+		log.Printf("failed to publish cache invalidation for order %s: %v", orderUID, err)
+	}
+}
+
+func invalidationsOrNop(p invalidation.Publisher) invalidation.Publisher {
+	if p == nil {
+		return invalidation.Nop{}
+	}
+	return p
+}
+
+// reject routes a message that could not be processed to the dead-letter
+// topic. Failed publishes are retried with the retry backoff until one
+// succeeds or ctx is done, so a message is never committed before it is
+// safely dead-lettered.
+//
+// Without a configured topic only permanent failures get here (transient
+// ones are retried forever); they are dropped and counted in
+// kafka_rejected{dropped}.
+func (c *Consumer) reject(ctx context.Context, m kafka.Message, reason error, attempts int) error {
+	if c.deadLetter == nil {
+		metrics.KafkaRejected.Add("dropped", 1)
+		log.Printf("no dead-letter topic configured, dropping message (partition %d, offset %d)", m.Partition, m.Offset)
+		return nil
+	}
+
+	for try := 1; ; try++ {
+		err := c.deadLetter.Publish(ctx, m, reason, attempts)
+		if err == nil {
+			metrics.KafkaRejected.Add("dead_lettered", 1)
+			return nil
+		}
+
+		delay := c.retry.backoff(try)
+		log.Printf("failed to publish message to dead-letter topic on attempt %d (partition %d, offset %d), retrying in %s: %v",
+			try, m.Partition, m.Offset, delay, err)
+		if err := sleepCtx(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
 func (c *Consumer) Close() error {
+	if c.deadLetter != nil {
+		if err := c.deadLetter.Close(); err != nil {
+			log.Printf("error closing dead-letter writer: %v", err)
+		}
+	}
 	return c.reader.Close()
 }
@@ -0,0 +1,127 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	defaultQueueSize = 64
+	commitInterval   = time.Second
+	shutdownTimeout  = 5 * time.Second
+)
+
+// startPool processes messages on a fixed set of workers. Messages with the
+// same key (order_uid) always land on the same worker, so they are handled
+// in the order they were fetched, while different orders run in parallel.
+func (c *Consumer) startPool(ctx context.Context) {
+	log.Printf("Kafka consumer started with %d workers...", c.workers)
+
+	tracker := newOffsetTracker()
+	queues := make([]chan kafka.Message, c.workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan kafka.Message, c.queueSize)
+		wg.Add(1)
+		go func(queue <-chan kafka.Message) {
+			defer wg.Done()
+			for m := range queue {
+				// process only gives up on shutdown, so every tracked offset
+				// is resolved before the next one; whatever is still tracked
+				// at shutdown stays uncommitted and is redelivered
+				if !c.process(ctx, m) {
+					return
+				}
+				tracker.markDone(m)
+			}
+		}(queues[i])
+	}
+
+	commitDone := make(chan struct{})
+	go func() {
+		defer close(commitDone)
+		ticker := time.NewTicker(commitInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.commit(ctx, tracker.committable())
+			}
+		}
+	}()
+
+	fetchFailures := 0
+	for ctx.Err() == nil {
+		m, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				fetchFailures++
+				log.Printf("error reading message: %v", err)
+				c.waitAfterFetchError(ctx, fetchFailures)
+			}
+			continue
+		}
+		fetchFailures = 0
+
+		tracker.track(m)
+		select {
+		case queues[c.workerFor(m)] <- m:
+		case <-ctx.Done():
+		}
+	}
+
+	for _, q := range queues {
+		close(q)
+	}
+	wg.Wait()
+	<-commitDone
+
+	// ctx is already cancelled here, flush what finished with a fresh one
+	flushCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	c.commit(flushCtx, tracker.committable())
+	if n := tracker.pending(); n > 0 {
+		log.Printf("%d uncommitted messages will be redelivered", n)
+	}
+	log.Println("Kafka consumer stopped by context")
+}
+
+func (c *Consumer) commit(ctx context.Context, msgs []kafka.Message) {
+	if len(msgs) == 0 {
+		return
+	}
+	if err := c.reader.CommitMessages(ctx, msgs...); err != nil {
+		log.Printf("failed to commit messages: %v", err)
+	}
+}
+
+func (c *Consumer) workerFor(m kafka.Message) int {
+	h := fnv.New32a()
+	h.Write([]byte(routingKey(m)))
+	return int(h.Sum32() % uint32(c.workers))
+}
+
+// routingKey prefers the message key, falls back to the order_uid from the
+// payload and finally to the partition, which keeps per-partition order.
+func routingKey(m kafka.Message) string {
+	if len(m.Key) > 0 {
+		return string(m.Key)
+	}
+
+	var probe struct {
+		OrderUID string `json:"order_uid"`
+	}
+	if err := json.Unmarshal(m.Value, &probe); err == nil && probe.OrderUID != "" {
+		return probe.OrderUID
+	}
+	return "partition-" + strconv.Itoa(m.Partition)
+}
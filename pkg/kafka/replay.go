@@ -0,0 +1,216 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"wb-task-L0/pkg/cache"
+	"wb-task-L0/pkg/codec"
+	"wb-task-L0/pkg/invalidation"
+	"wb-task-L0/pkg/repository"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ReplayConfig describes where to start re-reading a topic. Exactly one of
+// FromOffset, Since or Offsets should be set. Replay never joins a consumer
+// group, so committed offsets of the live consumers are left untouched.
+type ReplayConfig struct {
+	Brokers []string
+	Topic   string
+
+	// FromOffset is applied to every partition when >= 0.
+	FromOffset int64
+	// Since starts every partition at the first message at or after it.
+	Since time.Time
+	// Offsets maps partition to start offset and limits the replay to
+	// the listed partitions.
+	Offsets map[int]int64
+	// Until optionally stops the replay at messages newer than it.
+	Until time.Time
+
+	DryRun bool
+	Retry  RetryPolicy
+	Codecs *codec.Registry
+	// Invalidations lets running instances pick up replayed writes.
+	Invalidations invalidation.Publisher
+}
+
+type ReplayStats struct {
+	Read    int
+	Applied int
+	Skipped int
+	Failed  int
+}
+
+type Replayer struct {
+	cfg      ReplayConfig
+	consumer *Consumer
+}
+
+func NewReplayer(cfg ReplayConfig, repo repository.Order, cache cache.Cache) *Replayer {
+	return &Replayer{
+		cfg: cfg,
+		consumer: &Consumer{
+			retry:         cfg.Retry.withDefaults(),
+			codecs:        codecsOrDefault(cfg.Codecs),
+			orderRepo:     repo,
+			cache:         cache,
+			invalidations: invalidationsOrNop(cfg.Invalidations),
+		},
+	}
+}
+
+func (r *Replayer) Run(ctx context.Context) (ReplayStats, error) {
+	var stats ReplayStats
+
+	if len(r.cfg.Brokers) == 0 || r.cfg.Topic == "" {
+		return stats, errors.New("brokers and topic are required")
+	}
+	if r.cfg.FromOffset < 0 && r.cfg.Since.IsZero() && len(r.cfg.Offsets) == 0 {
+		return stats, errors.New("one of offset, since or partition offsets is required")
+	}
+
+	conn, err := kafka.DialContext(ctx, "tcp", r.cfg.Brokers[0])
+	if err != nil {
+		return stats, fmt.Errorf("dial broker: %w", err)
+	}
+	partitions, err := conn.ReadPartitions(r.cfg.Topic)
+	conn.Close()
+	if err != nil {
+		return stats, fmt.Errorf("read partitions: %w", err)
+	}
+
+	for _, p := range partitions {
+		if len(r.cfg.Offsets) > 0 {
+			if _, ok := r.cfg.Offsets[p.ID]; !ok {
+				continue
+			}
+		}
+		if err := r.replayPartition(ctx, p.ID, &stats); err != nil {
+			return stats, fmt.Errorf("partition %d: %w", p.ID, err)
+		}
+	}
+
+	return stats, nil
+}
+
+func (r *Replayer) replayPartition(ctx context.Context, partition int, stats *ReplayStats) error {
+	leader, err := kafka.DialLeader(ctx, "tcp", r.cfg.Brokers[0], r.cfg.Topic, partition)
+	if err != nil {
+		return err
+	}
+	first, last, err := leader.ReadOffsets()
+	if err != nil {
+		leader.Close()
+		return err
+	}
+
+	var start int64
+	switch {
+	case len(r.cfg.Offsets) > 0:
+		start = r.cfg.Offsets[partition]
+	case r.cfg.FromOffset >= 0:
+		start = r.cfg.FromOffset
+	default:
+		start, err = leader.ReadOffset(r.cfg.Since)
+	}
+	leader.Close()
+	if err != nil {
+		return err
+	}
+
+	if start < first {
+		start = first
+	}
+	if start >= last {
+		log.Printf("replay: partition %d has nothing to replay (start %d, end %d)", partition, start, last)
+		return nil
+	}
+	log.Printf("replay: partition %d from offset %d to %d", partition, start, last-1)
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   r.cfg.Brokers,
+		Topic:     r.cfg.Topic,
+		Partition: partition,
+	})
+	defer reader.Close()
+
+	if err := reader.SetOffset(start); err != nil {
+		return err
+	}
+
+	for {
+		m, err := reader.FetchMessage(ctx)
+		if err != nil {
+			return err
+		}
+		if !r.cfg.Until.IsZero() && m.Time.After(r.cfg.Until) {
+			return nil
+		}
+
+		r.replayMessage(ctx, m, stats)
+
+		if m.Offset >= last-1 {
+			return nil
+		}
+	}
+}
+
+func (r *Replayer) replayMessage(ctx context.Context, m kafka.Message, stats *ReplayStats) {
+	stats.Read++
+
+	if r.cfg.DryRun {
+		r.dryRun(ctx, m, stats)
+		return
+	}
+
+	if _, err := r.consumer.processWithRetry(ctx, m); err != nil {
+		stats.Failed++
+		log.Printf("replay: offset %d/%d failed: %v", m.Partition, m.Offset, err)
+		return
+	}
+	stats.Applied++
+}
+
+// dryRun counts the upserts that would be written as applied and those the
+// stored version would win against as skipped.
+func (r *Replayer) dryRun(ctx context.Context, m kafka.Message, stats *ReplayStats) {
+	ev, err := r.consumer.decodeEvent(m)
+	if err != nil {
+		stats.Failed++
+		log.Printf("replay: offset %d/%d would be rejected: %v", m.Partition, m.Offset, err)
+		return
+	}
+
+	switch ev.kind {
+	case eventSkip:
+		stats.Skipped++
+		return
+	case eventDelete:
+		stats.Applied++
+		log.Printf("replay: offset %d/%d would delete order %s", m.Partition, m.Offset, ev.orderUID)
+		return
+	}
+
+	order := ev.order
+	result, err := r.consumer.orderRepo.PreviewUpsert(ctx, &order)
+	if err != nil {
+		stats.Failed++
+		log.Printf("replay: offset %d/%d cannot check order %s: %v", m.Partition, m.Offset, ev.orderUID, err)
+		return
+	}
+	switch result {
+	case repository.UpsertCreated:
+		stats.Applied++
+		log.Printf("replay: offset %d/%d would insert order %s", m.Partition, m.Offset, ev.orderUID)
+	case repository.UpsertUpdated:
+		stats.Applied++
+		log.Printf("replay: offset %d/%d would update order %s", m.Partition, m.Offset, ev.orderUID)
+	default:
+		stats.Skipped++
+		log.Printf("replay: offset %d/%d would ignore order %s: stored version is newer or equal", m.Partition, m.Offset, ev.orderUID)
+	}
+}
@@ -0,0 +1,44 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"wb-task-L0/pkg/models"
+	"wb-task-L0/pkg/repository"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// previewRepo answers PreviewUpsert from a fixed table and fails any write.
+type previewRepo struct {
+	repository.Order
+	results map[string]repository.UpsertResult
+}
+
+func (r previewRepo) PreviewUpsert(_ context.Context, order *models.Order) (repository.UpsertResult, error) {
+	return r.results[order.OrderUID], nil
+}
+
+func TestReplayer_DryRun(t *testing.T) {
+	repo := previewRepo{results: map[string]repository.UpsertResult{
+		"new":     repository.UpsertCreated,
+		"changed": repository.UpsertUpdated,
+		"stale":   repository.UpsertIgnored,
+	}}
+	r := NewReplayer(ReplayConfig{DryRun: true}, repo, nil)
+
+	var stats ReplayStats
+	for _, m := range []kafka.Message{
+		orderMessage(t, 1, "new"),
+		orderMessage(t, 2, "changed"),
+		orderMessage(t, 3, "stale"),
+		{Offset: 4, Key: []byte("gone")},
+		{Offset: 5, Value: []byte("not json")},
+	} {
+		r.replayMessage(context.Background(), m, &stats)
+	}
+
+	assert.Equal(t, ReplayStats{Read: 5, Applied: 3, Skipped: 1, Failed: 1}, stats,
+		"insert, update and delete would apply, the stale order would be ignored")
+}
@@ -0,0 +1,92 @@
+package kafka
+
+import (
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// offsetTracker remembers in-flight messages per partition in fetch order so
+// that offsets are only committed up to the highest contiguous completed
+// message, even when workers finish out of order.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[int]*partitionOffsets
+}
+
+type partitionOffsets struct {
+	queue []*trackedMessage
+	index map[int64]*trackedMessage
+}
+
+type trackedMessage struct {
+	msg  kafka.Message
+	done bool
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[int]*partitionOffsets)}
+}
+
+func (t *offsetTracker) track(m kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[m.Partition]
+	if !ok {
+		p = &partitionOffsets{index: make(map[int64]*trackedMessage)}
+		t.partitions[m.Partition] = p
+	}
+	// after a rebalance an uncommitted offset can be delivered again, it
+	// keeps its place in the queue so one markDone completes it
+	if _, ok := p.index[m.Offset]; ok {
+		return
+	}
+	tm := &trackedMessage{msg: m}
+	p.queue = append(p.queue, tm)
+	p.index[m.Offset] = tm
+}
+
+func (t *offsetTracker) markDone(m kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if p, ok := t.partitions[m.Partition]; ok {
+		if tm, ok := p.index[m.Offset]; ok {
+			tm.done = true
+		}
+	}
+}
+
+// committable pops the completed prefix of every partition and returns the
+// last message of each prefix, ready to be passed to CommitMessages.
+func (t *offsetTracker) committable() []kafka.Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var msgs []kafka.Message
+	for _, p := range t.partitions {
+		n := 0
+		for n < len(p.queue) && p.queue[n].done {
+			delete(p.index, p.queue[n].msg.Offset)
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		msgs = append(msgs, p.queue[n-1].msg)
+		p.queue = p.queue[n:]
+	}
+	return msgs
+}
+
+func (t *offsetTracker) pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := 0
+	for _, p := range t.partitions {
+		n += len(p.queue)
+	}
+	return n
+}
@@ -0,0 +1,55 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func msg(partition int, offset int64) kafka.Message {
+	return kafka.Message{Partition: partition, Offset: offset}
+}
+
+func TestOffsetTracker_CommitsContiguousPrefix(t *testing.T) {
+	tr := newOffsetTracker()
+	for o := int64(10); o < 14; o++ {
+		tr.track(msg(0, o))
+	}
+	tr.track(msg(1, 5))
+
+	tr.markDone(msg(0, 11))
+	tr.markDone(msg(0, 12))
+	assert.Empty(t, tr.committable(), "offset 10 is still in flight")
+
+	tr.markDone(msg(0, 10))
+	tr.markDone(msg(1, 5))
+	got := tr.committable()
+	assert.ElementsMatch(t, []kafka.Message{msg(0, 12), msg(1, 5)}, got)
+	assert.Equal(t, 1, tr.pending())
+
+	tr.markDone(msg(0, 13))
+	assert.Equal(t, []kafka.Message{msg(0, 13)}, tr.committable())
+	assert.Zero(t, tr.pending())
+}
+
+func TestOffsetTracker_RedeliveredOffset(t *testing.T) {
+	tr := newOffsetTracker()
+	tr.track(msg(0, 10))
+	tr.track(msg(0, 11))
+	// a rebalance hands the uncommitted offsets out again
+	tr.track(msg(0, 10))
+	tr.track(msg(0, 11))
+	assert.Equal(t, 2, tr.pending())
+
+	tr.markDone(msg(0, 10))
+	tr.markDone(msg(0, 11))
+	assert.Equal(t, []kafka.Message{msg(0, 11)}, tr.committable())
+	assert.Zero(t, tr.pending())
+}
+
+func TestRoutingKey(t *testing.T) {
+	assert.Equal(t, "k1", routingKey(kafka.Message{Key: []byte("k1"), Value: []byte(`{"order_uid":"o1"}`)}))
+	assert.Equal(t, "o1", routingKey(kafka.Message{Value: []byte(`{"order_uid":"o1"}`)}))
+	assert.Equal(t, "partition-3", routingKey(kafka.Message{Partition: 3, Value: []byte(`garbage`)}))
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import "expvar"
+
+// Counters are published through expvar and served on /debug/vars.
+var (
+	// OrderUpserts counts consumer writes by outcome: created, updated, ignored.
+	OrderUpserts = expvar.NewMap("order_upserts")
+	// OrderDeletes counts orders removed by tombstones and delete events.
+	OrderDeletes = expvar.NewInt("order_deletes")
+	// KafkaRejected counts messages the consumer gave up on: dead_lettered,
+	// or dropped when no dead-letter topic is configured.
+	KafkaRejected = expvar.NewMap("kafka_rejected")
+
+	OutboxPublished = expvar.NewInt("outbox_published")
+	OutboxErrors    = expvar.NewInt("outbox_errors")
+	OutboxPending   = expvar.NewInt("outbox_pending")
+	// OutboxPruned counts sent events deleted after the retention.
+	OutboxPruned = expvar.NewInt("outbox_pruned")
+	// OutboxLagSeconds is the age of the oldest unsent outbox event.
+	OutboxLagSeconds = expvar.NewFloat("outbox_lag_seconds")
+
+	// CacheInvalidations counts cross-instance invalidations: sent,
+	// received, resyncs and errors.
+	CacheInvalidations = expvar.NewMap("cache_invalidations")
+	// CacheLoads counts GetByID cache misses by whether they queried the
+	// database (db) or joined a load already in flight (shared).
+	CacheLoads = expvar.NewMap("cache_loads")
+)
@@ -1,64 +1,112 @@
 package models
 
-import "time"
+import (
+	"slices"
+	"time"
+)
 
 type Order struct {
-	OrderUID          string    `json:"order_uid" gorm:"column:order_uid;primaryKey"`
-	TrackNumber       string    `json:"track_number" gorm:"column:track_number"`
-	Entry             string    `json:"entry" gorm:"column:entry"`
-	Locale            string    `json:"locale" gorm:"column:locale"`
-	InternalSignature string    `json:"internal_signature" gorm:"column:internal_signature"`
-	CustomerID        string    `json:"customer_id" gorm:"column:customer_id"`
-	DeliveryService   string    `json:"delivery_service" gorm:"column:delivery_service"`
-	ShardKey          string    `json:"shard_key" gorm:"column:shard_key"`
-	SmID              int       `json:"sm_id" gorm:"column:sm_id"`
-	DateCreated       time.Time `json:"date_created" gorm:"column:date_created"`
-	OofShard          string    `json:"oof_shard" gorm:"column:oof_shard"`
+	OrderUID          string    `json:"order_uid" gorm:"column:order_uid;primaryKey" avro:"order_uid"`
+	TrackNumber       string    `json:"track_number" gorm:"column:track_number" avro:"track_number"`
+	Entry             string    `json:"entry" gorm:"column:entry" avro:"entry"`
+	Locale            string    `json:"locale" gorm:"column:locale" avro:"locale"`
+	InternalSignature string    `json:"internal_signature" gorm:"column:internal_signature" avro:"internal_signature"`
+	CustomerID        string    `json:"customer_id" gorm:"column:customer_id" avro:"customer_id"`
+	DeliveryService   string    `json:"delivery_service" gorm:"column:delivery_service" avro:"delivery_service"`
+	ShardKey          string    `json:"shard_key" gorm:"column:shard_key" avro:"shard_key"`
+	SmID              int       `json:"sm_id" gorm:"column:sm_id" avro:"sm_id"`
+	DateCreated       time.Time `json:"date_created" gorm:"column:date_created" avro:"date_created"`
+	OofShard          string    `json:"oof_shard" gorm:"column:oof_shard" avro:"oof_shard"`
 
-	Delivery Delivery `json:"delivery" gorm:"foreignKey:OrderUID;references:OrderUID"`
-	Payment  Payment  `json:"payment" gorm:"foreignKey:OrderUID;references:OrderUID"`
-	Items    []Item   `json:"items" gorm:"foreignKey:OrderUID;references:OrderUID"`
+	// EventVersion is an optional upstream version of the order; EventTime is
+	// the Kafka message time (or date_created). Together they decide whether
+	// a redelivered order replaces the stored one.
+	EventVersion int64     `json:"version,omitempty" gorm:"column:event_version" avro:"version"`
+	EventTime    time.Time `json:"-" gorm:"column:event_time"`
+	// Revision is bumped on every write and served as the order's ETag.
+	// Unlike EventVersion it belongs to this service, not to upstream.
+	Revision int64 `json:"-" gorm:"column:revision"`
+
+	Delivery Delivery `json:"delivery" gorm:"foreignKey:OrderUID;references:OrderUID" avro:"delivery"`
+	Payment  Payment  `json:"payment" gorm:"foreignKey:OrderUID;references:OrderUID" avro:"payment"`
+	Items    []Item   `json:"items" gorm:"foreignKey:OrderUID;references:OrderUID" avro:"items"`
+}
+
+// Clone returns a deep copy. Delivery and Payment are plain values, so only
+// Items needs copying.
+func (o *Order) Clone() Order {
+	c := *o
+	if o.Items != nil {
+		c.Items = slices.Clone(o.Items)
+	}
+	return c
 }
 
 type Delivery struct {
-	DeliveryID string `json:"delivery_id" gorm:"column:delivery_id;primaryKey"`
-	OrderUID   string `json:"order_uid" gorm:"column:order_uid"`
-	Name       string `json:"name" gorm:"column:name"`
-	Phone      string `json:"phone" gorm:"column:phone"`
-	Zip        string `json:"zip" gorm:"column:zip"`
-	City       string `json:"city" gorm:"column:city"`
-	Address    string `json:"address" gorm:"column:address"`
-	Region     string `json:"region" gorm:"column:region"`
-	Email      string `json:"email" gorm:"column:email"`
+	DeliveryID string `json:"delivery_id" gorm:"column:delivery_id;primaryKey" avro:"delivery_id"`
+	OrderUID   string `json:"order_uid" gorm:"column:order_uid" avro:"order_uid"`
+	Name       string `json:"name" gorm:"column:name" avro:"name"`
+	Phone      string `json:"phone" gorm:"column:phone" avro:"phone"`
+	Zip        string `json:"zip" gorm:"column:zip" avro:"zip"`
+	City       string `json:"city" gorm:"column:city" avro:"city"`
+	Address    string `json:"address" gorm:"column:address" avro:"address"`
+	Region     string `json:"region" gorm:"column:region" avro:"region"`
+	Email      string `json:"email" gorm:"column:email" avro:"email"`
 }
 
 type Payment struct {
-	PaymentID    string  `json:"payment_id" gorm:"column:payment_id;primaryKey"`
-	OrderUID     string  `json:"order_uid" gorm:"column:order_uid"`
-	Transaction  string  `json:"transaction" gorm:"column:transaction"`
-	RequestID    string  `json:"request_id" gorm:"column:request_id"`
-	Currency     string  `json:"currency" gorm:"column:currency"`
-	Provider     string  `json:"provider" gorm:"column:provider"`
-	Amount       float64 `json:"amount" gorm:"column:amount"`
-	PaymentDt    int64   `json:"payment_dt" gorm:"column:payment_dt"`
-	Bank         string  `json:"bank" gorm:"column:bank"`
-	DeliveryCost float64 `json:"delivery_cost" gorm:"column:delivery_cost"`
-	GoodsTotal   float64 `json:"goods_total" gorm:"column:goods_total"`
-	CustomFee    float64 `json:"custom_fee" gorm:"column:custom_fee"`
+	PaymentID    string  `json:"payment_id" gorm:"column:payment_id;primaryKey" avro:"payment_id"`
+	OrderUID     string  `json:"order_uid" gorm:"column:order_uid" avro:"order_uid"`
+	Transaction  string  `json:"transaction" gorm:"column:transaction" avro:"transaction"`
+	RequestID    string  `json:"request_id" gorm:"column:request_id" avro:"request_id"`
+	Currency     string  `json:"currency" gorm:"column:currency" avro:"currency"`
+	Provider     string  `json:"provider" gorm:"column:provider" avro:"provider"`
+	Amount       float64 `json:"amount" gorm:"column:amount" avro:"amount"`
+	PaymentDt    int64   `json:"payment_dt" gorm:"column:payment_dt" avro:"payment_dt"`
+	Bank         string  `json:"bank" gorm:"column:bank" avro:"bank"`
+	DeliveryCost float64 `json:"delivery_cost" gorm:"column:delivery_cost" avro:"delivery_cost"`
+	GoodsTotal   float64 `json:"goods_total" gorm:"column:goods_total" avro:"goods_total"`
+	CustomFee    float64 `json:"custom_fee" gorm:"column:custom_fee" avro:"custom_fee"`
 }
 
 type Item struct {
-	ItemID      string  `json:"item_id" gorm:"column:item_id;primaryKey"`
-	OrderUID    string  `json:"order_uid" gorm:"column:order_uid"`
-	ChrtID      int64   `json:"chrt_id" gorm:"column:chrt_id"`
-	TrackNumber string  `json:"track_number" gorm:"column:track_number"`
-	Price       float64 `json:"price" gorm:"column:price"`
-	Rid         string  `json:"rid" gorm:"column:rid"`
-	Name        string  `json:"name" gorm:"column:name"`
-	Sale        float64 `json:"sale" gorm:"column:sale"`
-	Size        string  `json:"size" gorm:"column:size"`
-	TotalPrice  float64 `json:"total_price" gorm:"column:total_price"`
-	NmID        int64   `json:"nm_id" gorm:"column:nm_id"`
-	Brand       string  `json:"brand" gorm:"column:brand"`
-	Status      int     `json:"status" gorm:"column:status"`
+	ItemID      string  `json:"item_id" gorm:"column:item_id;primaryKey" avro:"item_id"`
+	OrderUID    string  `json:"order_uid" gorm:"column:order_uid" avro:"order_uid"`
+	ChrtID      int64   `json:"chrt_id" gorm:"column:chrt_id" avro:"chrt_id"`
+	TrackNumber string  `json:"track_number" gorm:"column:track_number" avro:"track_number"`
+	Price       float64 `json:"price" gorm:"column:price" avro:"price"`
+	Rid         string  `json:"rid" gorm:"column:rid" avro:"rid"`
+	Name        string  `json:"name" gorm:"column:name" avro:"name"`
+	Sale        float64 `json:"sale" gorm:"column:sale" avro:"sale"`
+	Size        string  `json:"size" gorm:"column:size" avro:"size"`
+	TotalPrice  float64 `json:"total_price" gorm:"column:total_price" avro:"total_price"`
+	NmID        int64   `json:"nm_id" gorm:"column:nm_id" avro:"nm_id"`
+	Brand       string  `json:"brand" gorm:"column:brand" avro:"brand"`
+	Status      int     `json:"status" gorm:"column:status" avro:"status"`
+}
+
+type OutboxEvent struct {
+	ID          int64      `json:"id" gorm:"column:id;primaryKey"`
+	AggregateID string     `json:"aggregate_id" gorm:"column:aggregate_id"`
+	EventType   string     `json:"event_type" gorm:"column:event_type"`
+	Payload     []byte     `json:"payload" gorm:"column:payload;type:jsonb"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"column:created_at"`
+	SentAt      *time.Time `json:"sent_at" gorm:"column:sent_at"`
+}
+
+func (OutboxEvent) TableName() string {
+	return "outbox"
+}
+
+// OrderTombstone is what remains of a deleted order: the upstream version
+// and event time it was deleted at, so an older upsert replayed after the
+// delete doesn't bring the order back.
+type OrderTombstone struct {
+	OrderUID     string    `gorm:"column:order_uid;primaryKey"`
+	EventVersion int64     `gorm:"column:event_version"`
+	EventTime    time.Time `gorm:"column:event_time"`
+}
+
+func (OrderTombstone) TableName() string {
+	return "order_tombstones"
 }
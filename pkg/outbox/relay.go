@@ -0,0 +1,183 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+	"wb-task-L0/pkg/metrics"
+	"wb-task-L0/pkg/models"
+	"wb-task-L0/pkg/repository"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	HeaderEventType   = "event-type"
+	HeaderContentType = "content-type"
+	HeaderOutboxID    = "x-outbox-id"
+
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 100
+	defaultRetention    = 7 * 24 * time.Hour
+	defaultPruneEvery   = time.Hour
+	pruneBatch          = 1000
+)
+
+type Config struct {
+	Brokers      []string
+	Topic        string
+	PollInterval time.Duration
+	BatchSize    int
+	// Retention keeps sent events this long before they are deleted. The
+	// outbox doubles as the change log a cache snapshot catches up from, so
+	// it must be longer than the snapshot max age.
+	Retention  time.Duration
+	PruneEvery time.Duration
+}
+
+// writer is the part of *kafka.Writer the relay uses.
+type writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Relay publishes events written to the outbox table to Kafka. Rows are only
+// marked as sent after the broker acknowledged them, so every event is
+// delivered at least once; consumers should dedupe by x-outbox-id. Sent rows
+// are deleted once they are older than the retention.
+type Relay struct {
+	repo         repository.Outbox
+	writer       writer
+	pollInterval time.Duration
+	batchSize    int
+	retention    time.Duration
+	pruneEvery   time.Duration
+}
+
+func NewRelay(cfg Config, repo repository.Outbox) *Relay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = defaultRetention
+	}
+	if cfg.PruneEvery <= 0 {
+		cfg.PruneEvery = defaultPruneEvery
+	}
+
+	return &Relay{
+		repo: repo,
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(cfg.Brokers...),
+			Topic:                  cfg.Topic,
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+		},
+		pollInterval: cfg.PollInterval,
+		batchSize:    cfg.BatchSize,
+		retention:    cfg.Retention,
+		pruneEvery:   cfg.PruneEvery,
+	}
+}
+
+func (r *Relay) Start(ctx context.Context) {
+	log.Println("Outbox relay started...")
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+	prune := time.NewTicker(r.pruneEvery)
+	defer prune.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Outbox relay stopped by context")
+			return
+		case <-ticker.C:
+			r.drain(ctx)
+			r.updateLag(ctx)
+		case <-prune.C:
+			r.prune(ctx)
+		}
+	}
+}
+
+// drain publishes batches until the outbox is empty or an error occurs.
+func (r *Relay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := r.repo.ProcessPending(ctx, r.batchSize, func(events []models.OutboxEvent) error {
+			return r.writer.WriteMessages(ctx, toMessages(events)...)
+		})
+		if err != nil {
+			metrics.OutboxErrors.Add(1)
+			log.Printf("outbox relay: failed to publish events: %v", err)
+			return
+		}
+		metrics.OutboxPublished.Add(int64(n))
+		if n < r.batchSize {
+			return
+		}
+	}
+}
+
+// prune deletes sent events older than the retention a batch at a time,
+// so no single statement holds locks on a large part of the table.
+func (r *Relay) prune(ctx context.Context) {
+	before := time.Now().Add(-r.retention)
+	var total int64
+	for ctx.Err() == nil {
+		n, err := r.repo.DeleteSent(ctx, before, pruneBatch)
+		if err != nil {
+			log.Printf("outbox relay: failed to delete sent events: %v", err)
+			break
+		}
+		total += n
+		if n < pruneBatch {
+			break
+		}
+	}
+	if total > 0 {
+		metrics.OutboxPruned.Add(total)
+		log.Printf("outbox relay: deleted %d events sent before %s", total, before.Format(time.RFC3339))
+	}
+}
+
+func (r *Relay) updateLag(ctx context.Context) {
+	pending, oldest, err := r.repo.Pending(ctx)
+	if err != nil {
+		log.Printf("outbox relay: failed to read pending events: %v", err)
+		return
+	}
+
+	metrics.OutboxPending.Set(pending)
+	if oldest.IsZero() {
+		metrics.OutboxLagSeconds.Set(0)
+		return
+	}
+	metrics.OutboxLagSeconds.Set(time.Since(oldest).Seconds())
+}
+
+func toMessages(events []models.OutboxEvent) []kafka.Message {
+	msgs := make([]kafka.Message, 0, len(events))
+	for _, e := range events {
+		msgs = append(msgs, kafka.Message{
+			Key:   []byte(e.AggregateID),
+			Value: e.Payload,
+			Headers: []kafka.Header{
+				{Key: HeaderEventType, Value: []byte(e.EventType)},
+				{Key: HeaderContentType, Value: []byte("application/json")},
+				{Key: HeaderOutboxID, Value: []byte(strconv.FormatInt(e.ID, 10))},
+			},
+		})
+	}
+	return msgs
+}
+
+func (r *Relay) Close() error {
+	return r.writer.Close()
+}
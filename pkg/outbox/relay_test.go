@@ -0,0 +1,215 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"wb-task-L0/pkg/models"
+	"wb-task-L0/pkg/repository"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryOutbox keeps events in memory and claims them like OutboxRepo: a
+// failed publish leaves the batch unsent.
+type memoryOutbox struct {
+	repository.Outbox
+	mu     sync.Mutex
+	events []models.OutboxEvent
+}
+
+func (o *memoryOutbox) ProcessPending(_ context.Context, limit int, publish func([]models.OutboxEvent) error) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var idx []int
+	var batch []models.OutboxEvent
+	for i, e := range o.events {
+		if e.SentAt == nil && len(batch) < limit {
+			idx = append(idx, i)
+			batch = append(batch, e)
+		}
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	if err := publish(batch); err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	for _, i := range idx {
+		o.events[i].SentAt = &now
+	}
+	return len(batch), nil
+}
+
+func (o *memoryOutbox) Pending(context.Context) (int64, time.Time, error) {
+	return int64(len(o.unsent())), time.Time{}, nil
+}
+
+func (o *memoryOutbox) DeleteSent(_ context.Context, before time.Time, limit int) (int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var kept []models.OutboxEvent
+	var n int64
+	for _, e := range o.events {
+		if e.SentAt != nil && e.SentAt.Before(before) && n < int64(limit) {
+			n++
+			continue
+		}
+		kept = append(kept, e)
+	}
+	o.events = kept
+	return n, nil
+}
+
+func (o *memoryOutbox) unsent() []int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var ids []int64
+	for _, e := range o.events {
+		if e.SentAt == nil {
+			ids = append(ids, e.ID)
+		}
+	}
+	return ids
+}
+
+func (o *memoryOutbox) len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.events)
+}
+
+// fakeWriter fails the first failures writes.
+type fakeWriter struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	written  []kafka.Message
+}
+
+func (w *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.calls++
+	if w.calls <= w.failures {
+		return errors.New("broker unavailable")
+	}
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+func (w *fakeWriter) messages() []kafka.Message {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]kafka.Message(nil), w.written...)
+}
+
+func pendingEvents(n int) []models.OutboxEvent {
+	events := make([]models.OutboxEvent, n)
+	for i := range events {
+		events[i] = models.OutboxEvent{
+			ID:          int64(i + 1),
+			AggregateID: "order" + strconv.Itoa(i+1),
+			EventType:   repository.EventOrderAccepted,
+			Payload:     []byte(`{}`),
+		}
+	}
+	return events
+}
+
+func newTestRelay(repo repository.Outbox, w writer) *Relay {
+	return &Relay{
+		repo:         repo,
+		writer:       w,
+		pollInterval: time.Millisecond,
+		batchSize:    2,
+		retention:    time.Hour,
+		pruneEvery:   time.Hour,
+	}
+}
+
+func TestRelay_PublishesPendingEvents(t *testing.T) {
+	repo := &memoryOutbox{events: pendingEvents(3)}
+	w := &fakeWriter{}
+	r := newTestRelay(repo, w)
+
+	r.drain(context.Background())
+
+	assert.Empty(t, repo.unsent(), "batches are drained until the outbox is empty")
+	msgs := w.messages()
+	require.Len(t, msgs, 3)
+	assert.Equal(t, "order1", string(msgs[0].Key))
+	assert.Contains(t, msgs[0].Headers, kafka.Header{Key: HeaderOutboxID, Value: []byte("1")})
+	assert.Contains(t, msgs[0].Headers, kafka.Header{Key: HeaderEventType, Value: []byte(repository.EventOrderAccepted)})
+}
+
+func TestRelay_RetriesFailedWrites(t *testing.T) {
+	repo := &memoryOutbox{events: pendingEvents(2)}
+	w := &fakeWriter{failures: 2}
+	r := newTestRelay(repo, w)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		r.Start(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return len(repo.unsent()) == 0 }, time.Second, time.Millisecond)
+	assert.Len(t, w.messages(), 2, "failed writes are not marked sent and go out on a later poll")
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("relay did not stop after the context was canceled")
+	}
+}
+
+func TestRelay_StopsOnCancel(t *testing.T) {
+	repo := &memoryOutbox{events: pendingEvents(1)}
+	r := newTestRelay(repo, &fakeWriter{failures: 1 << 30})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Start(ctx)
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("relay did not stop after the context was canceled")
+	}
+	assert.Equal(t, []int64{1}, repo.unsent(), "nothing is marked sent while the broker is down")
+}
+
+func TestRelay_PrunesSentEvents(t *testing.T) {
+	old, recent := time.Now().Add(-2*time.Hour), time.Now()
+	events := pendingEvents(pruneBatch + 3)
+	for i := range events[:pruneBatch+1] {
+		events[i].SentAt = &old
+	}
+	events[pruneBatch+1].SentAt = &recent
+
+	repo := &memoryOutbox{events: events}
+	newTestRelay(repo, &fakeWriter{}).prune(context.Background())
+
+	assert.Equal(t, 2, repo.len(), "old sent events are deleted across batches")
+	assert.Equal(t, []int64{pruneBatch + 3}, repo.unsent(), "unsent events are kept")
+}
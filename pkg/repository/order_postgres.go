@@ -2,8 +2,13 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"slices"
+	"strings"
+	"time"
 	"wb-task-L0/pkg/models"
 )
 
@@ -15,12 +20,18 @@ func NewOrderRepo(db *gorm.DB) *OrderRepo {
 	return &OrderRepo{db: db}
 }
 
+// firstRevision is the revision of a newly inserted order, every later
+// write bumps it by one.
+const firstRevision = 1
+
 func (r *OrderRepo) Create(order *models.Order) (string, error) {
+	stampEventTime(order)
+	order.Revision = firstRevision
 	err := r.db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(&order).Error; err != nil {
 			return err
 		}
-		return nil
+		return enqueueOrderEvent(tx, EventOrderAccepted, order)
 	})
 
 	if err != nil {
@@ -36,31 +47,377 @@ func (r *OrderRepo) CreateOrderWithAssociations(ctx context.Context, order *mode
 			return nil
 		}
 
+		stampEventTime(order)
+		order.Revision = firstRevision
 		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(order).Error; err != nil {
 			return err
 		}
 
-		order.Delivery.DeliveryID = order.Delivery.DeliveryID + "_" + order.OrderUID
+		prepareAssociations(order)
 		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&order.Delivery).Error; err != nil {
 			return err
 		}
 
-		order.Payment.PaymentID = order.Payment.PaymentID + "_" + order.OrderUID
 		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&order.Payment).Error; err != nil {
 			return err
 		}
 
-		for i := range order.Items {
-			order.Items[i].ItemID = order.Items[i].ItemID + "_" + order.OrderUID
-		}
 		if len(order.Items) > 0 {
 			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&order.Items).Error; err != nil {
 				return err
 			}
 		}
 
+		return enqueueOrderEvent(tx, EventOrderAccepted, order)
+	})
+}
+
+type UpsertResult int
+
+const (
+	UpsertCreated UpsertResult = iota
+	UpsertUpdated
+	UpsertIgnored
+)
+
+func (r UpsertResult) String() string {
+	switch r {
+	case UpsertCreated:
+		return "created"
+	case UpsertUpdated:
+		return "updated"
+	default:
+		return "ignored"
+	}
+}
+
+// UpsertOrderWithAssociations inserts the order or, when it already exists
+// and the incoming version is newer, replaces the order row together with
+// its delivery, payment and items. Older or equal versions are ignored.
+func (r *OrderRepo) UpsertOrderWithAssociations(ctx context.Context, order *models.Order) (UpsertResult, error) {
+	var result UpsertResult
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = upsertOrder(tx, order)
+		return err
+	})
+	return result, err
+}
+
+// UpsertOrdersBatch upserts many orders in one transaction. Orders that do
+// not exist yet are written with multi-row inserts per table, the rest go
+// through the versioned upsert in their original order. The returned
+// results are aligned with orders.
+func (r *OrderRepo) UpsertOrdersBatch(ctx context.Context, orders []*models.Order) ([]UpsertResult, error) {
+	results := make([]UpsertResult, len(orders))
+	if len(orders) == 0 {
+		return results, nil
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		uids := make([]string, 0, len(orders))
+		for _, o := range orders {
+			uids = append(uids, o.OrderUID)
+		}
+
+		var existing []string
+		if err := tx.Model(&models.Order{}).Where("order_uid IN ?", uids).Pluck("order_uid", &existing).Error; err != nil {
+			return err
+		}
+		// deleted orders go through the versioned upsert too, it checks
+		// their tombstones
+		var deleted []string
+		if err := tx.Model(&models.OrderTombstone{}).Where("order_uid IN ?", uids).Pluck("order_uid", &deleted).Error; err != nil {
+			return err
+		}
+		seen := make(map[string]bool, len(orders))
+		for _, uid := range slices.Concat(existing, deleted) {
+			seen[uid] = true
+		}
+
+		var (
+			newOrders  []*models.Order
+			deliveries []*models.Delivery
+			payments   []*models.Payment
+			items      []*models.Item
+			events     []*models.OutboxEvent
+			upserts    []int
+		)
+		for i, o := range orders {
+			if seen[o.OrderUID] {
+				upserts = append(upserts, i)
+				continue
+			}
+			seen[o.OrderUID] = true
+
+			stampEventTime(o)
+			o.Revision = firstRevision
+			prepareAssociations(o)
+			newOrders = append(newOrders, o)
+			deliveries = append(deliveries, &o.Delivery)
+			payments = append(payments, &o.Payment)
+			for j := range o.Items {
+				items = append(items, &o.Items[j])
+			}
+			payload, err := json.Marshal(o)
+			if err != nil {
+				return err
+			}
+			events = append(events, &models.OutboxEvent{AggregateID: o.OrderUID, EventType: EventOrderAccepted, Payload: payload})
+			results[i] = UpsertCreated
+		}
+
+		if len(newOrders) > 0 {
+			if err := tx.Omit(clause.Associations).Create(&newOrders).Error; err != nil {
+				return err
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error; err != nil {
+				return err
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&payments).Error; err != nil {
+				return err
+			}
+			if len(items) > 0 {
+				if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&items).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Create(&events).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, i := range upserts {
+			result, err := upsertOrder(tx, orders[i])
+			if err != nil {
+				return err
+			}
+			results[i] = result
+		}
+
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func upsertOrder(tx *gorm.DB, order *models.Order) (UpsertResult, error) {
+	stampEventTime(order)
+
+	var existing models.Order
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("order_uid", "event_version", "event_time", "revision").
+		Where("order_uid = ?", order.OrderUID).
+		Take(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return insertOrder(tx, order)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if !isNewer(&existing, order) {
+		return UpsertIgnored, nil
+	}
+	order.Revision = existing.Revision + 1
+	return UpsertUpdated, replaceOrder(tx, order)
+}
+
+// insertOrder creates an order that doesn't exist. If it was deleted, the
+// order is only recreated when it is newer than the delete.
+func insertOrder(tx *gorm.DB, order *models.Order) (UpsertResult, error) {
+	deleted, err := deletedVersion(tx, order.OrderUID)
+	if err != nil {
+		return 0, err
+	}
+	if deleted != nil {
+		if !isNewer(deleted, order) {
+			return UpsertIgnored, nil
+		}
+		if err := tx.Where("order_uid = ?", order.OrderUID).Delete(&models.OrderTombstone{}).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	order.Revision = firstRevision
+	prepareAssociations(order)
+	if err := tx.Omit(clause.Associations).Create(order).Error; err != nil {
+		return 0, err
+	}
+	if err := insertAssociations(tx, order); err != nil {
+		return 0, err
+	}
+	return UpsertCreated, enqueueOrderEvent(tx, EventOrderAccepted, order)
+}
+
+// deletedVersion returns the version and event time the order was deleted
+// at, or nil if it has no tombstone.
+func deletedVersion(tx *gorm.DB, orderUID string) (*models.Order, error) {
+	var tombstone models.OrderTombstone
+	err := tx.Where("order_uid = ?", orderUID).Take(&tombstone).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	return &models.Order{EventVersion: tombstone.EventVersion, EventTime: tombstone.EventTime}, nil
+}
+
+// PreviewUpsert reports what UpsertOrderWithAssociations would do with the
+// order, without writing anything.
+func (r *OrderRepo) PreviewUpsert(ctx context.Context, order *models.Order) (UpsertResult, error) {
+	incoming := *order
+	stampEventTime(&incoming)
+
+	tx := r.db.WithContext(ctx)
+	var existing models.Order
+	err := tx.Select("order_uid", "event_version", "event_time").
+		Where("order_uid = ?", order.OrderUID).
+		Take(&existing).Error
+	switch {
+	case err == nil:
+		if isNewer(&existing, &incoming) {
+			return UpsertUpdated, nil
+		}
+		return UpsertIgnored, nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return 0, err
+	}
+
+	deleted, err := deletedVersion(tx, order.OrderUID)
+	if err != nil {
+		return 0, err
+	}
+	if deleted != nil && !isNewer(deleted, &incoming) {
+		return UpsertIgnored, nil
+	}
+	return UpsertCreated, nil
+}
+
+// UpdateOrder locks the order, hands its current state to update and
+// replaces the order row and its delivery, payment and items with the
+// result, all in one transaction. An error from update rolls it back.
+//
+// The upstream version is kept and the event time moves to now, so Kafka
+// redeliveries older than the edit don't undo it.
+func (r *OrderRepo) UpdateOrder(ctx context.Context, id string, update func(*models.Order) error) (models.Order, error) {
+	var order models.Order
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Delivery").
+			Preload("Payment").
+			Preload("Items").
+			Where("order_uid = ?", id).
+			Take(&current).Error; err != nil {
+			return err
+		}
+
+		order = current.Clone()
+		if err := update(&order); err != nil {
+			return err
+		}
+		order.EventVersion = current.EventVersion
+		order.EventTime = time.Now()
+		order.Revision = current.Revision + 1
+		return replaceOrder(tx, &order)
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+	return order, nil
+}
+
+// replaceOrder overwrites an existing order row and swaps its associations
+// for the ones on order. The caller sets the new revision.
+func replaceOrder(tx *gorm.DB, order *models.Order) error {
+	prepareAssociations(order)
+	if err := tx.Omit(clause.Associations).Save(order).Error; err != nil {
+		return err
+	}
+	if err := deleteAssociations(tx, order.OrderUID); err != nil {
+		return err
+	}
+	if err := insertAssociations(tx, order); err != nil {
+		return err
+	}
+	return enqueueOrderEvent(tx, EventOrderUpdated, order)
+}
+
+// isNewer compares explicit versions when either side carries one and
+// falls back to the event time otherwise.
+func isNewer(existing, incoming *models.Order) bool {
+	if existing.EventVersion != 0 || incoming.EventVersion != 0 {
+		return incoming.EventVersion > existing.EventVersion
+	}
+	return incoming.EventTime.After(existing.EventTime)
+}
+
+func stampEventTime(order *models.Order) {
+	if order.EventTime.IsZero() {
+		order.EventTime = order.DateCreated
+	}
+}
+
+func insertAssociations(tx *gorm.DB, order *models.Order) error {
+	if err := tx.Create(&order.Delivery).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(&order.Payment).Error; err != nil {
+		return err
+	}
+	if len(order.Items) > 0 {
+		if err := tx.Create(&order.Items).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteAssociations(tx *gorm.DB, orderUID string) error {
+	if err := tx.Where("order_uid = ?", orderUID).Delete(&models.Item{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("order_uid = ?", orderUID).Delete(&models.Payment{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("order_uid = ?", orderUID).Delete(&models.Delivery{}).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// prepareAssociations links delivery, payment and items to the order and
+// makes their ids unique per order, since upstream reuses them. It is
+// idempotent, so retried writes don't suffix twice, and gives the order its
+// own Items slice, so an order sharing items with another (a cached copy, a
+// retried message) is not rewritten behind its back.
+func prepareAssociations(order *models.Order) {
+	order.Delivery.OrderUID = order.OrderUID
+	order.Delivery.DeliveryID = scopedID(order.Delivery.DeliveryID, order.OrderUID)
+
+	order.Payment.OrderUID = order.OrderUID
+	order.Payment.PaymentID = scopedID(order.Payment.PaymentID, order.OrderUID)
+
+	order.Items = slices.Clone(order.Items)
+	for i := range order.Items {
+		order.Items[i].OrderUID = order.OrderUID
+		order.Items[i].ItemID = scopedID(order.Items[i].ItemID, order.OrderUID)
+	}
+}
+
+func scopedID(id, orderUID string) string {
+	suffix := "_" + orderUID
+	if strings.HasSuffix(id, suffix) {
+		return id
+	}
+	return id + suffix
 }
 
 func (r *OrderRepo) GetAll() ([]models.Order, error) {
@@ -71,6 +428,101 @@ func (r *OrderRepo) GetAll() ([]models.Order, error) {
 	return orders, nil
 }
 
+// GetPage returns up to limit orders newest first, continuing after the given
+// order (nil for the first page). Keyset pagination on
+// (date_created, order_uid) keeps every page an index range scan, however
+// deep into the table it is. A non-zero since skips older orders.
+func (r *OrderRepo) GetPage(ctx context.Context, after *models.Order, since time.Time, limit int) ([]models.Order, error) {
+	q := r.db.WithContext(ctx).
+		Preload("Delivery").
+		Preload("Payment").
+		Preload("Items").
+		Order("date_created DESC, order_uid DESC").
+		Limit(limit)
+	if !since.IsZero() {
+		q = q.Where("date_created >= ?", since)
+	}
+	if after != nil {
+		q = q.Where("(date_created, order_uid) < (?, ?)", after.DateCreated, after.OrderUID)
+	}
+
+	var orders []models.Order
+	if err := q.Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// Count returns the number of orders created at or after since, all of them
+// when since is zero.
+func (r *OrderRepo) Count(ctx context.Context, since time.Time) (int64, error) {
+	q := r.db.WithContext(ctx).Model(&models.Order{})
+	if !since.IsZero() {
+		q = q.Where("date_created >= ?", since)
+	}
+	var n int64
+	err := q.Count(&n).Error
+	return n, err
+}
+
+func (r *OrderRepo) preloaded(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).
+		Preload("Delivery").
+		Preload("Payment").
+		Preload("Items")
+}
+
+// TrackOrderIDs lists the orders with this track number newest first,
+// using orders_track_number_idx. Like CustomerOrderIDs it only returns ids,
+// so the caller can load just the orders its cache is missing.
+func (r *OrderRepo) TrackOrderIDs(ctx context.Context, track string) ([]string, error) {
+	var ids []string
+	if err := r.db.WithContext(ctx).Model(&models.Order{}).
+		Where("track_number = ?", track).
+		Order("date_created DESC").
+		Pluck("order_uid", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// TransactionOrderIDs lists the orders paid with this transaction newest
+// first, using payments_transaction_idx.
+func (r *OrderRepo) TransactionOrderIDs(ctx context.Context, transaction string) ([]string, error) {
+	var ids []string
+	if err := r.db.WithContext(ctx).Model(&models.Order{}).
+		Where("order_uid IN (?)", r.db.Model(&models.Payment{}).Select("order_uid").Where("transaction = ?", transaction)).
+		Order("date_created DESC").
+		Pluck("order_uid", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// CustomerOrderIDs is an index only scan on orders_customer_id_idx, cheap
+// enough to learn which of a customer's orders are missing from the cache.
+func (r *OrderRepo) CustomerOrderIDs(ctx context.Context, customerID string) ([]string, error) {
+	var ids []string
+	if err := r.db.WithContext(ctx).Model(&models.Order{}).
+		Where("customer_id = ?", customerID).
+		Order("date_created DESC").
+		Pluck("order_uid", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *OrderRepo) GetByIDs(ctx context.Context, ids []string) ([]models.Order, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var orders []models.Order
+	if err := r.preloaded(ctx).Where("order_uid IN ?", ids).Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
 func (r *OrderRepo) GetByID(orderUID string) (models.Order, error) {
 	var order models.Order
 
@@ -85,24 +537,82 @@ func (r *OrderRepo) GetByID(orderUID string) (models.Order, error) {
 	return order, nil
 }
 
+// Delete removes the order as of now. Deleting a missing order is not an
+// error.
 func (r *OrderRepo) Delete(orderUID string) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Where("order_uid = ?", orderUID).Delete(&models.Item{}).Error; err != nil {
-			return err
-		}
+		return deleteOrder(tx, orderUID, 0, time.Now())
+	})
+}
 
-		if err := tx.Where("order_uid = ?", orderUID).Delete(&models.Payment{}).Error; err != nil {
-			return err
-		}
+// DeleteVersion removes the order for an upstream delete event with the
+// given version (zero if it has none) and event time. The tombstone it
+// leaves makes upserts that are not newer than the delete no-ops.
+func (r *OrderRepo) DeleteVersion(ctx context.Context, orderUID string, version int64, eventTime time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return deleteOrder(tx, orderUID, version, eventTime)
+	})
+}
 
-		if err := tx.Where("order_uid = ?", orderUID).Delete(&models.Delivery{}).Error; err != nil {
+// PruneTombstones deletes up to limit tombstones of orders deleted before
+// the given time and returns how many it deleted. Past that point an
+// upsert replayed from the topic can bring the order back.
+func (r *OrderRepo) PruneTombstones(ctx context.Context, before time.Time, limit int) (int64, error) {
+	uids := r.db.Model(&models.OrderTombstone{}).
+		Select("order_uid").
+		Where("deleted_at < ?", before).
+		Limit(limit)
+	res := r.db.WithContext(ctx).Where("order_uid IN (?)", uids).Delete(&models.OrderTombstone{})
+	return res.RowsAffected, res.Error
+}
+
+// DeleteOrder locks the order row and deletes the order only if check
+// accepts it. Unlike Delete it reports a missing order as
+// gorm.ErrRecordNotFound.
+func (r *OrderRepo) DeleteOrder(ctx context.Context, orderUID string, check func(*models.Order) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("order_uid = ?", orderUID).
+			Take(&current).Error; err != nil {
 			return err
 		}
-
-		if err := tx.Where("order_uid = ?", orderUID).Delete(&models.Order{}).Error; err != nil {
+		if err := check(&current); err != nil {
 			return err
 		}
+		return deleteOrder(tx, orderUID, 0, time.Now())
+	})
+}
 
+// tombstoneSQL records a delete at the later of the delete's own version and
+// event time and those of the order it removes, so the tombstone is never
+// older than what was deleted. GREATEST skips the NULLs of a missing order.
+const tombstoneSQL = `
+INSERT INTO order_tombstones (order_uid, event_version, event_time)
+SELECT @uid, GREATEST(@version, MAX(event_version)), GREATEST(@time, MAX(event_time))
+FROM orders WHERE order_uid = @uid
+ON CONFLICT (order_uid) DO UPDATE SET
+	event_version = GREATEST(order_tombstones.event_version, EXCLUDED.event_version),
+	event_time = GREATEST(order_tombstones.event_time, EXCLUDED.event_time),
+	deleted_at = now()`
+
+// deleteOrder leaves a tombstone even when the order doesn't exist, so a
+// delete that overtook the order's creation still wins.
+func deleteOrder(tx *gorm.DB, orderUID string, version int64, eventTime time.Time) error {
+	if err := tx.Exec(tombstoneSQL, map[string]any{"uid": orderUID, "version": version, "time": eventTime}).Error; err != nil {
+		return err
+	}
+	if err := deleteAssociations(tx, orderUID); err != nil {
+		return err
+	}
+
+	res := tx.Where("order_uid = ?", orderUID).Delete(&models.Order{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
 		return nil
-	})
+	}
+
+	return enqueueDeleteEvent(tx, orderUID)
 }
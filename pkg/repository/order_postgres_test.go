@@ -1,6 +1,8 @@
 package repository_test
 
 import (
+	"context"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	sqlmock "github.com/zhashkevych/go-sqlxmock"
@@ -88,6 +90,9 @@ func TestOrderRepo_Create(t *testing.T) {
 			order.SmID,
 			sqlmock.AnyArg(),
 			order.OofShard,
+			order.EventVersion,
+			sqlmock.AnyArg(),
+			1,
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
@@ -140,6 +145,10 @@ func TestOrderRepo_Create(t *testing.T) {
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
+	mock.ExpectQuery(`INSERT INTO "outbox"`).
+		WithArgs(order.OrderUID, "order.accepted", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
 	mock.ExpectCommit()
 
 	gotUID, err := repo.Create(order)
@@ -328,6 +337,10 @@ func TestOrderRepo_Delete(t *testing.T) {
 
 	mock.ExpectBegin()
 
+	mock.ExpectExec(`INSERT INTO order_tombstones .* SELECT \$1, GREATEST\(\$2, MAX\(event_version\)\), GREATEST\(\$3, MAX\(event_time\)\) FROM orders WHERE order_uid = \$4 ON CONFLICT`).
+		WithArgs(orderUID, int64(0), sqlmock.AnyArg(), orderUID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
 	mock.ExpectExec(`DELETE FROM "items" WHERE order_uid = \$1`).
 		WithArgs(orderUID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
@@ -344,6 +357,10 @@ func TestOrderRepo_Delete(t *testing.T) {
 		WithArgs(orderUID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
+	mock.ExpectQuery(`INSERT INTO "outbox"`).
+		WithArgs(orderUID, "order.deleted", []byte(`{"order_uid":"order123","type":"order.deleted"}`), sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
 	mock.ExpectCommit()
 
 	err = repo.Delete(orderUID)
@@ -351,3 +368,507 @@ func TestOrderRepo_Delete(t *testing.T) {
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestOrderRepo_UpsertOrdersBatch(t *testing.T) {
+	db, mock, err := newGormMock()
+	require.NoError(t, err)
+
+	repo := repository.NewOrderRepo(db)
+
+	newOrder := func(uid string, version int64) *models.Order {
+		return &models.Order{
+			OrderUID:     uid,
+			TrackNumber:  "track456",
+			DateCreated:  time.Now(),
+			EventVersion: version,
+			Delivery:     models.Delivery{DeliveryID: "del1", Name: "John"},
+			Payment:      models.Payment{PaymentID: "pay1", Transaction: "tx123"},
+			Items: []models.Item{
+				{ItemID: "it1", ChrtID: 1, Name: "item1"},
+				{ItemID: "it2", ChrtID: 2, Name: "item2"},
+			},
+		}
+	}
+	stale, fresh := newOrder("order1", 3), newOrder("order2", 1)
+
+	mock.ExpectBegin()
+
+	mock.ExpectQuery(`SELECT "order_uid" FROM "orders" WHERE order_uid IN \(\$1,\$2\)`).
+		WithArgs("order1", "order2").
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid"}).AddRow("order1"))
+
+	mock.ExpectQuery(`SELECT "order_uid" FROM "order_tombstones" WHERE order_uid IN \(\$1,\$2\)`).
+		WithArgs("order1", "order2").
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid"}))
+
+	mock.ExpectExec(`INSERT INTO "orders" .* VALUES \(\$1,\$2,\$3,\$4,\$5,\$6,\$7,\$8,\$9,\$10,\$11,\$12,\$13,\$14\)$`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectExec(`INSERT INTO "deliveries"`).
+		WithArgs("del1_order2", "order2", "John", "", "", "", "", "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectExec(`INSERT INTO "payments"`).
+		WithArgs("pay1_order2", "order2", "tx123", "", "", "", 0.0, int64(0), "", 0.0, 0.0, 0.0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectExec(`INSERT INTO "items" .* VALUES \(.*\),\(.*\) ON CONFLICT DO NOTHING`).
+		WillReturnResult(sqlmock.NewResult(2, 2))
+
+	mock.ExpectQuery(`INSERT INTO "outbox"`).
+		WithArgs("order2", "order.accepted", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	mock.ExpectQuery(`SELECT "order_uid","event_version","event_time","revision" FROM "orders" WHERE order_uid = \$1 LIMIT \$2 FOR UPDATE`).
+		WithArgs("order1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid", "event_version", "event_time", "revision"}).
+			AddRow("order1", 5, time.Now(), 3))
+
+	mock.ExpectCommit()
+
+	results, err := repo.UpsertOrdersBatch(context.Background(), []*models.Order{stale, fresh})
+	require.NoError(t, err)
+
+	assert.Equal(t, []repository.UpsertResult{repository.UpsertIgnored, repository.UpsertCreated}, results)
+	assert.Equal(t, "del1", stale.Delivery.DeliveryID, "ignored orders are left untouched")
+	assert.Equal(t, "it2_order2", fresh.Items[1].ItemID)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepo_UpsertOrderWithAssociations_Update(t *testing.T) {
+	db, mock, err := newGormMock()
+	require.NoError(t, err)
+
+	repo := repository.NewOrderRepo(db)
+
+	order := &models.Order{
+		OrderUID:    "order123",
+		TrackNumber: "track456",
+		DateCreated: time.Now(),
+		EventTime:   time.Now(),
+		Delivery:    models.Delivery{DeliveryID: "del1", Name: "John"},
+		Payment:     models.Payment{PaymentID: "pay1", Transaction: "tx123"},
+		Items:       []models.Item{{ItemID: "it1", ChrtID: 1, Name: "item1"}},
+	}
+
+	mock.ExpectBegin()
+
+	mock.ExpectQuery(`SELECT "order_uid","event_version","event_time","revision" FROM "orders" WHERE order_uid = \$1 LIMIT \$2 FOR UPDATE`).
+		WithArgs(order.OrderUID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid", "event_version", "event_time", "revision"}).
+			AddRow(order.OrderUID, 0, order.EventTime.Add(-time.Hour), 2))
+
+	mock.ExpectExec(`UPDATE "orders" SET .* WHERE "order_uid" = \$14`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectExec(`DELETE FROM "items" WHERE order_uid = \$1`).
+		WithArgs(order.OrderUID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "payments" WHERE order_uid = \$1`).
+		WithArgs(order.OrderUID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "deliveries" WHERE order_uid = \$1`).
+		WithArgs(order.OrderUID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectExec(`INSERT INTO "deliveries"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO "payments"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO "items"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`INSERT INTO "outbox"`).
+		WithArgs(order.OrderUID, "order.updated", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	mock.ExpectCommit()
+
+	result, err := repo.UpsertOrderWithAssociations(context.Background(), order)
+	require.NoError(t, err)
+
+	assert.Equal(t, repository.UpsertUpdated, result)
+	assert.Equal(t, "del1_order123", order.Delivery.DeliveryID)
+	assert.Equal(t, int64(3), order.Revision)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepo_UpsertOrderWithAssociations_Deleted(t *testing.T) {
+	db, mock, err := newGormMock()
+	require.NoError(t, err)
+
+	repo := repository.NewOrderRepo(db)
+	order := &models.Order{OrderUID: "order123", EventVersion: 3, DateCreated: time.Now()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "order_uid","event_version","event_time","revision" FROM "orders" WHERE order_uid = \$1 LIMIT \$2 FOR UPDATE`).
+		WithArgs("order123", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid", "event_version", "event_time", "revision"}))
+	mock.ExpectQuery(`SELECT \* FROM "order_tombstones" WHERE order_uid = \$1 LIMIT \$2`).
+		WithArgs("order123", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid", "event_version", "event_time"}).
+			AddRow("order123", 3, time.Now()))
+	mock.ExpectCommit()
+
+	result, err := repo.UpsertOrderWithAssociations(context.Background(), order)
+	require.NoError(t, err)
+	assert.Equal(t, repository.UpsertIgnored, result, "a replay not newer than the delete doesn't bring the order back")
+
+	order.EventVersion = 4
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "order_uid","event_version","event_time","revision" FROM "orders" WHERE order_uid = \$1 LIMIT \$2 FOR UPDATE`).
+		WithArgs("order123", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid", "event_version", "event_time", "revision"}))
+	mock.ExpectQuery(`SELECT \* FROM "order_tombstones" WHERE order_uid = \$1 LIMIT \$2`).
+		WithArgs("order123", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid", "event_version", "event_time"}).
+			AddRow("order123", 3, time.Now()))
+	mock.ExpectExec(`DELETE FROM "order_tombstones" WHERE order_uid = \$1`).
+		WithArgs("order123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO "orders"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO "deliveries"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO "payments"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`INSERT INTO "outbox"`).
+		WithArgs("order123", "order.accepted", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	result, err = repo.UpsertOrderWithAssociations(context.Background(), order)
+	require.NoError(t, err)
+	assert.Equal(t, repository.UpsertCreated, result, "a newer version recreates the order")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepo_PreviewUpsert(t *testing.T) {
+	db, mock, err := newGormMock()
+	require.NoError(t, err)
+
+	repo := repository.NewOrderRepo(db)
+	now := time.Now()
+	expectStored := func(rows *sqlmock.Rows) {
+		mock.ExpectQuery(`SELECT "order_uid","event_version","event_time" FROM "orders" WHERE order_uid = \$1 LIMIT \$2$`).
+			WithArgs("order123", 1).
+			WillReturnRows(rows)
+	}
+	columns := []string{"order_uid", "event_version", "event_time"}
+
+	expectStored(sqlmock.NewRows(columns).AddRow("order123", 2, now))
+	result, err := repo.PreviewUpsert(context.Background(), &models.Order{OrderUID: "order123", EventVersion: 2})
+	require.NoError(t, err)
+	assert.Equal(t, repository.UpsertIgnored, result)
+
+	expectStored(sqlmock.NewRows(columns).AddRow("order123", 2, now))
+	result, err = repo.PreviewUpsert(context.Background(), &models.Order{OrderUID: "order123", EventVersion: 3})
+	require.NoError(t, err)
+	assert.Equal(t, repository.UpsertUpdated, result)
+
+	expectStored(sqlmock.NewRows(columns))
+	mock.ExpectQuery(`SELECT \* FROM "order_tombstones" WHERE order_uid = \$1 LIMIT \$2`).
+		WithArgs("order123", 1).
+		WillReturnRows(sqlmock.NewRows(columns))
+	result, err = repo.PreviewUpsert(context.Background(), &models.Order{OrderUID: "order123", EventVersion: 1})
+	require.NoError(t, err)
+	assert.Equal(t, repository.UpsertCreated, result)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepo_GetPage(t *testing.T) {
+	db, mock, err := newGormMock()
+	require.NoError(t, err)
+
+	repo := repository.NewOrderRepo(db)
+
+	since := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	after := &models.Order{OrderUID: "b", DateCreated: since.Add(time.Hour)}
+
+	mock.ExpectQuery(`SELECT \* FROM "orders" WHERE date_created >= \$1 AND \(date_created, order_uid\) < \(\$2, \$3\) ORDER BY date_created DESC, order_uid DESC LIMIT \$4`).
+		WithArgs(since, after.DateCreated, after.OrderUID, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid", "date_created"}).
+			AddRow("a", since.Add(time.Minute)))
+	mock.ExpectQuery(`SELECT .* FROM "deliveries" WHERE "deliveries"."order_uid" = \$1`).
+		WithArgs("a").
+		WillReturnRows(sqlmock.NewRows([]string{"delivery_id", "order_uid"}))
+	mock.ExpectQuery(`SELECT .* FROM "payments" WHERE "payments"."order_uid" = \$1`).
+		WithArgs("a").
+		WillReturnRows(sqlmock.NewRows([]string{"payment_id", "order_uid"}))
+	mock.ExpectQuery(`SELECT .* FROM "items" WHERE "items"."order_uid" = \$1`).
+		WithArgs("a").
+		WillReturnRows(sqlmock.NewRows([]string{"item_id", "order_uid"}))
+
+	got, err := repo.GetPage(context.Background(), after, since, 2)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "a", got[0].OrderUID)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutboxRepo_ChangedSince(t *testing.T) {
+	db, mock, err := newGormMock()
+	require.NoError(t, err)
+
+	repo := repository.NewOutboxRepo(db)
+	since := time.Now().Add(-time.Hour)
+
+	mock.ExpectQuery(`SELECT DISTINCT ON \(aggregate_id\) aggregate_id, event_type FROM "outbox" WHERE created_at >= \$1 ORDER BY aggregate_id, id DESC`).
+		WithArgs(since).
+		WillReturnRows(sqlmock.NewRows([]string{"aggregate_id", "event_type"}).
+			AddRow("a", repository.EventOrderUpdated).
+			AddRow("b", repository.EventOrderDeleted).
+			AddRow("c", repository.EventOrderAccepted))
+
+	changed, deleted, err := repo.ChangedSince(context.Background(), since)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, changed)
+	assert.Equal(t, []string{"b"}, deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepo_TransactionOrderIDs(t *testing.T) {
+	db, mock, err := newGormMock()
+	require.NoError(t, err)
+
+	repo := repository.NewOrderRepo(db)
+
+	mock.ExpectQuery(`SELECT "order_uid" FROM "orders" WHERE order_uid IN \(SELECT "order_uid" FROM "payments" WHERE transaction = \$1\) ORDER BY date_created DESC`).
+		WithArgs("tx1").
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid"}).AddRow("b").AddRow("a"))
+
+	got, err := repo.TransactionOrderIDs(context.Background(), "tx1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "a"}, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepo_List(t *testing.T) {
+	db, mock, err := newGormMock()
+	require.NoError(t, err)
+
+	repo := repository.NewOrderRepo(db)
+
+	t0 := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	status := 202
+	cursor := &repository.Cursor{DateCreated: t0.Add(time.Hour), OrderUID: "c"}
+
+	mock.ExpectQuery(`SELECT \* FROM "orders" WHERE customer_id = \$1 AND date_created >= \$2 `+
+		`AND EXISTS \(SELECT 1 FROM "payments" WHERE payments.order_uid = orders.order_uid AND payments.currency = \$3\) `+
+		`AND EXISTS \(SELECT 1 FROM "items" WHERE items.order_uid = orders.order_uid AND items.brand = \$4 AND items.status = \$5\) `+
+		`AND \(date_created, order_uid\) < \(\$6, \$7\) ORDER BY date_created DESC, order_uid DESC LIMIT \$8`).
+		WithArgs("cust", t0, "RUB", "Vivienne Sabo", status, cursor.DateCreated, "c", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid", "date_created"}).
+			AddRow("b", t0.Add(2*time.Minute)).
+			AddRow("a", t0.Add(time.Minute)).
+			AddRow("0", t0))
+	for _, table := range []string{"deliveries", "payments", "items"} {
+		mock.ExpectQuery(`SELECT .* FROM "` + table + `" WHERE "` + table + `"."order_uid" IN \(\$1,\$2,\$3\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"order_uid"}))
+	}
+
+	page, err := repo.List(context.Background(), repository.OrderQuery{
+		CustomerID: "cust",
+		Currency:   "RUB",
+		Brand:      "Vivienne Sabo",
+		Status:     &status,
+		From:       t0,
+		Cursor:     cursor,
+		Limit:      2,
+	})
+	require.NoError(t, err)
+	require.Len(t, page.Orders, 2)
+	assert.Equal(t, "b", page.Orders[0].OrderUID)
+	require.NotNil(t, page.Next)
+	assert.Equal(t, "a", page.Next.OrderUID)
+	require.NotNil(t, page.Prev)
+	assert.Equal(t, "b", page.Prev.OrderUID)
+	assert.True(t, page.Prev.Backward)
+
+	decoded, err := repository.DecodeCursor(page.Prev.Encode())
+	require.NoError(t, err)
+	assert.Equal(t, *page.Prev, decoded)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepo_List_Backward(t *testing.T) {
+	db, mock, err := newGormMock()
+	require.NoError(t, err)
+
+	repo := repository.NewOrderRepo(db)
+
+	t0 := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	cursor := &repository.Cursor{DateCreated: t0, OrderUID: "a", Backward: true}
+
+	mock.ExpectQuery(`SELECT \* FROM "orders" WHERE \(date_created, order_uid\) > \(\$1, \$2\) ORDER BY date_created ASC, order_uid ASC LIMIT \$3`).
+		WithArgs(t0, "a", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid", "date_created"}).
+			AddRow("b", t0.Add(time.Minute)).
+			AddRow("c", t0.Add(2*time.Minute)))
+	for _, table := range []string{"deliveries", "payments", "items"} {
+		mock.ExpectQuery(`SELECT .* FROM "` + table + `"`).
+			WillReturnRows(sqlmock.NewRows([]string{"order_uid"}))
+	}
+
+	page, err := repo.List(context.Background(), repository.OrderQuery{Cursor: cursor, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Orders, 2)
+	assert.Equal(t, "c", page.Orders[0].OrderUID, "backward pages keep the requested sort order")
+	assert.Nil(t, page.Prev, "no more pages before this one")
+	require.NotNil(t, page.Next)
+	assert.Equal(t, "b", page.Next.OrderUID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepo_Search(t *testing.T) {
+	db, mock, err := newGormMock()
+	require.NoError(t, err)
+
+	repo := repository.NewOrderRepo(db)
+
+	mock.ExpectQuery(`WITH q AS \(\s*SELECT websearch_to_tsquery\('simple', \$1\) AS query\s*\).*`+
+		`ORDER BY score DESC, order_uid\s+LIMIT \$6 OFFSET \$7`).
+		WithArgs("ivanov", "ivanov", "ivanov", "ivanov", "ivanov", 3, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid", "score"}).
+			AddRow("b", 1.5).
+			AddRow("a", 0.4).
+			AddRow("c", 0.3))
+	// the orders come back in table order, Search restores the ranking
+	mock.ExpectQuery(`SELECT \* FROM "orders" WHERE order_uid IN \(\$1,\$2\)`).
+		WithArgs("b", "a").
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid"}).AddRow("a").AddRow("b"))
+	for _, table := range []string{"deliveries", "payments", "items"} {
+		mock.ExpectQuery(`SELECT .* FROM "` + table + `" WHERE "` + table + `"."order_uid" IN \(\$1,\$2\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"order_uid"}))
+	}
+
+	orders, more, err := repo.Search(context.Background(), "ivanov", 2, 10)
+	require.NoError(t, err)
+	assert.True(t, more)
+	require.Len(t, orders, 2)
+	assert.Equal(t, "b", orders[0].OrderUID)
+	assert.Equal(t, "a", orders[1].OrderUID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepo_UpdateOrder(t *testing.T) {
+	db, mock, err := newGormMock()
+	require.NoError(t, err)
+
+	repo := repository.NewOrderRepo(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "orders" WHERE order_uid = \$1 LIMIT \$2 FOR UPDATE`).
+		WithArgs("order123", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid", "track_number", "event_version", "revision"}).
+			AddRow("order123", "track456", 7, 4))
+	mock.ExpectQuery(`SELECT \* FROM "deliveries" WHERE "deliveries"."order_uid" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"delivery_id", "order_uid", "address"}).
+			AddRow("del1_order123", "order123", "Red Square, 1"))
+	mock.ExpectQuery(`SELECT \* FROM "payments" WHERE "payments"."order_uid" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"payment_id", "order_uid"}).AddRow("pay1_order123", "order123"))
+	mock.ExpectQuery(`SELECT \* FROM "items" WHERE "items"."order_uid" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"item_id", "order_uid"}).AddRow("it1_order123", "order123"))
+
+	mock.ExpectExec(`UPDATE "orders" SET .* WHERE "order_uid" = \$14`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	for _, table := range []string{"items", "payments", "deliveries"} {
+		mock.ExpectExec(`DELETE FROM "` + table + `" WHERE order_uid = \$1`).
+			WithArgs("order123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec(`INSERT INTO "deliveries"`).
+		WithArgs("del1_order123", "order123", "", "", "", "", "Ploshad Mira 15", "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO "payments"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO "items"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`INSERT INTO "outbox"`).
+		WithArgs("order123", "order.updated", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	order, err := repo.UpdateOrder(context.Background(), "order123", func(o *models.Order) error {
+		o.Delivery.Address = "Ploshad Mira 15"
+		o.EventVersion = 1
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Ploshad Mira 15", order.Delivery.Address)
+	assert.Equal(t, int64(7), order.EventVersion, "the upstream version is not editable")
+	assert.Equal(t, int64(5), order.Revision)
+	assert.False(t, order.EventTime.IsZero())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepo_UpdateOrder_Rollback(t *testing.T) {
+	db, mock, err := newGormMock()
+	require.NoError(t, err)
+
+	repo := repository.NewOrderRepo(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "orders" WHERE order_uid = \$1 LIMIT \$2 FOR UPDATE`).
+		WithArgs("missing", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid"}))
+	mock.ExpectRollback()
+
+	_, err = repo.UpdateOrder(context.Background(), "missing", func(*models.Order) error {
+		t.Fatal("update called for a missing order")
+		return nil
+	})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepo_DeleteOrder(t *testing.T) {
+	db, mock, err := newGormMock()
+	require.NoError(t, err)
+
+	repo := repository.NewOrderRepo(db)
+	errStale := errors.New("stale revision")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "orders" WHERE order_uid = \$1 LIMIT \$2 FOR UPDATE`).
+		WithArgs("order123", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid", "revision"}).AddRow("order123", 3))
+	mock.ExpectRollback()
+
+	err = repo.DeleteOrder(context.Background(), "order123", func(o *models.Order) error {
+		assert.Equal(t, int64(3), o.Revision)
+		return errStale
+	})
+	assert.ErrorIs(t, err, errStale)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "orders" WHERE order_uid = \$1 LIMIT \$2 FOR UPDATE`).
+		WithArgs("order123", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid", "revision"}).AddRow("order123", 3))
+	mock.ExpectExec(`INSERT INTO order_tombstones .* SELECT \$1, GREATEST\(\$2, MAX\(event_version\)\), GREATEST\(\$3, MAX\(event_time\)\) FROM orders WHERE order_uid = \$4 ON CONFLICT`).
+		WithArgs("order123", int64(0), sqlmock.AnyArg(), "order123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	for _, table := range []string{"items", "payments", "deliveries", "orders"} {
+		mock.ExpectExec(`DELETE FROM "` + table + `" WHERE order_uid = \$1`).
+			WithArgs("order123").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectQuery(`INSERT INTO "outbox"`).
+		WithArgs("order123", "order.deleted", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.DeleteOrder(context.Background(), "order123", func(*models.Order) error { return nil }))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepo_PruneTombstones(t *testing.T) {
+	db, mock, err := newGormMock()
+	require.NoError(t, err)
+	repo := repository.NewOrderRepo(db)
+	before := time.Now().Add(-720 * time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "order_tombstones" WHERE order_uid IN \(SELECT "order_uid" FROM "order_tombstones" WHERE deleted_at < \$1 LIMIT \$2\)`).
+		WithArgs(before, 1000).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	n, err := repo.PruneTombstones(context.Background(), before, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+	"wb-task-L0/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// OrderQuery selects a page of orders sorted by date_created, with
+// order_uid breaking ties. Empty filters are ignored.
+type OrderQuery struct {
+	CustomerID      string
+	DeliveryService string
+	Locale          string
+	// payment filters
+	Currency string
+	Provider string
+	// item filters, both must match the same item
+	Brand  string
+	Status *int
+	// date_created in [From, To)
+	From time.Time
+	To   time.Time
+
+	Ascending bool
+	Cursor    *Cursor
+	Limit     int
+}
+
+// Cursor marks a page boundary. Backward cursors page towards the start of
+// the sort order.
+type Cursor struct {
+	DateCreated time.Time `json:"t"`
+	OrderUID    string    `json:"id"`
+	Backward    bool      `json:"b,omitempty"`
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.OrderUID == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+type OrderPage struct {
+	Orders []models.Order
+	Next   *Cursor
+	Prev   *Cursor
+}
+
+// List runs the query entirely in SQL with keyset pagination, so deep pages
+// cost the same as the first one. It fetches one row more than asked to
+// learn whether another page follows.
+func (r *OrderRepo) List(ctx context.Context, q OrderQuery) (OrderPage, error) {
+	backward := q.Cursor != nil && q.Cursor.Backward
+	// walking backwards flips the sort, the rows are reversed afterwards
+	asc := q.Ascending != backward
+
+	db := r.preloaded(ctx)
+	db = applyOrderFilters(db, q)
+
+	if q.Cursor != nil {
+		op := "<"
+		if asc {
+			op = ">"
+		}
+		db = db.Where("(date_created, order_uid) "+op+" (?, ?)", q.Cursor.DateCreated, q.Cursor.OrderUID)
+	}
+	if asc {
+		db = db.Order("date_created ASC, order_uid ASC")
+	} else {
+		db = db.Order("date_created DESC, order_uid DESC")
+	}
+
+	var orders []models.Order
+	if err := db.Limit(q.Limit + 1).Find(&orders).Error; err != nil {
+		return OrderPage{}, err
+	}
+
+	more := len(orders) > q.Limit
+	if more {
+		orders = orders[:q.Limit]
+	}
+	if backward {
+		slices.Reverse(orders)
+	}
+
+	page := OrderPage{Orders: orders}
+	if len(orders) == 0 {
+		return page, nil
+	}
+	first, last := cursorOf(&orders[0]), cursorOf(&orders[len(orders)-1])
+	first.Backward = true
+
+	// the cursor we came from proves there is a page on that side
+	if backward {
+		page.Next = &last
+		if more {
+			page.Prev = &first
+		}
+	} else {
+		if more {
+			page.Next = &last
+		}
+		if q.Cursor != nil {
+			page.Prev = &first
+		}
+	}
+	return page, nil
+}
+
+func cursorOf(o *models.Order) Cursor {
+	return Cursor{DateCreated: o.DateCreated, OrderUID: o.OrderUID}
+}
+
+func applyOrderFilters(db *gorm.DB, q OrderQuery) *gorm.DB {
+	if q.CustomerID != "" {
+		db = db.Where("customer_id = ?", q.CustomerID)
+	}
+	if q.DeliveryService != "" {
+		db = db.Where("delivery_service = ?", q.DeliveryService)
+	}
+	if q.Locale != "" {
+		db = db.Where("locale = ?", q.Locale)
+	}
+	if !q.From.IsZero() {
+		db = db.Where("date_created >= ?", q.From)
+	}
+	if !q.To.IsZero() {
+		db = db.Where("date_created < ?", q.To)
+	}
+
+	if q.Currency != "" || q.Provider != "" {
+		sub := db.Session(&gorm.Session{NewDB: true}).Table("payments").Select("1").Where("payments.order_uid = orders.order_uid")
+		if q.Currency != "" {
+			sub = sub.Where("payments.currency = ?", q.Currency)
+		}
+		if q.Provider != "" {
+			sub = sub.Where("payments.provider = ?", q.Provider)
+		}
+		db = db.Where("EXISTS (?)", sub)
+	}
+
+	if q.Brand != "" || q.Status != nil {
+		sub := db.Session(&gorm.Session{NewDB: true}).Table("items").Select("1").Where("items.order_uid = orders.order_uid")
+		if q.Brand != "" {
+			sub = sub.Where("items.brand = ?", q.Brand)
+		}
+		if q.Status != nil {
+			sub = sub.Where("items.status = ?", *q.Status)
+		}
+		db = db.Where("EXISTS (?)", sub)
+	}
+	return db
+}
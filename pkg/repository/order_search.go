@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"wb-task-L0/pkg/models"
+)
+
+// deliveryDocument must match the indexed expressions in
+// migration/000008_order_search.up.sql, or the indexes are not used.
+const deliveryDocument = `(coalesce(d.name, '') || ' ' || coalesce(d.city, '') || ' ' || coalesce(d.address, '') ` +
+	`|| ' ' || coalesce(d.email, '') || ' ' || coalesce(d.phone, ''))`
+
+// searchQuery ranks orders by their best matching delivery or item. Full-text
+// matches weigh double, trigram word similarity catches typos and partial
+// phone numbers or emails that the tokenizer splits differently.
+const searchQuery = `
+WITH q AS (
+    SELECT websearch_to_tsquery('simple', @term) AS query
+), matches AS (
+    SELECT d.order_uid,
+           2 * ts_rank(to_tsvector('simple', ` + deliveryDocument + `), q.query)
+             + word_similarity(@term, ` + deliveryDocument + `) AS score
+    FROM deliveries d, q
+    WHERE to_tsvector('simple', ` + deliveryDocument + `) @@ q.query
+       OR @term <% ` + deliveryDocument + `
+    UNION ALL
+    SELECT i.order_uid,
+           2 * ts_rank(to_tsvector('simple', i.name), q.query)
+             + word_similarity(@term, i.name) AS score
+    FROM items i, q
+    WHERE to_tsvector('simple', i.name) @@ q.query
+       OR @term <% i.name
+)
+SELECT order_uid, max(score) AS score
+FROM matches
+GROUP BY order_uid
+ORDER BY score DESC, order_uid
+LIMIT @limit OFFSET @offset`
+
+type searchHit struct {
+	OrderUID string
+	Score    float64
+}
+
+// Search returns up to limit orders matching term, best first, and whether
+// more results follow.
+func (r *OrderRepo) Search(ctx context.Context, term string, limit, offset int) ([]models.Order, bool, error) {
+	var hits []searchHit
+	if err := r.db.WithContext(ctx).Raw(searchQuery, map[string]any{
+		"term":   term,
+		"limit":  limit + 1,
+		"offset": offset,
+	}).Scan(&hits).Error; err != nil {
+		return nil, false, err
+	}
+
+	more := len(hits) > limit
+	if more {
+		hits = hits[:limit]
+	}
+
+	ids := make([]string, len(hits))
+	for i, h := range hits {
+		ids[i] = h.OrderUID
+	}
+	orders, err := r.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// GetByIDs does not keep the ranking order
+	byID := make(map[string]models.Order, len(orders))
+	for _, o := range orders {
+		byID[o.OrderUID] = o
+	}
+	ranked := make([]models.Order, 0, len(orders))
+	for _, id := range ids {
+		if o, ok := byID[id]; ok {
+			ranked = append(ranked, o)
+		}
+	}
+	return ranked, more, nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"wb-task-L0/pkg/models"
+)
+
+const (
+	EventOrderAccepted = "order.accepted"
+	EventOrderUpdated  = "order.updated"
+	EventOrderDeleted  = "order.deleted"
+)
+
+type OutboxRepo struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepo(db *gorm.DB) *OutboxRepo {
+	return &OutboxRepo{db: db}
+}
+
+// ProcessPending locks up to limit unsent events, hands them to publish and
+// marks them as sent if publish succeeds. Locked rows are skipped by other
+// instances, and a failed publish leaves them pending for the next run.
+func (r *OutboxRepo) ProcessPending(ctx context.Context, limit int, publish func([]models.OutboxEvent) error) (int, error) {
+	var n int
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var events []models.OutboxEvent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("sent_at IS NULL").
+			Order("id").
+			Limit(limit).
+			Find(&events).Error; err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		if err := publish(events); err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(events))
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+		n = len(events)
+		return tx.Model(&models.OutboxEvent{}).Where("id IN ?", ids).Update("sent_at", time.Now()).Error
+	})
+	return n, err
+}
+
+// Pending returns the number of unsent events and the creation time of the
+// oldest one, zero when nothing is pending.
+func (r *OutboxRepo) Pending(ctx context.Context) (int64, time.Time, error) {
+	var row struct {
+		Count  int64
+		Oldest *time.Time
+	}
+	if err := r.db.WithContext(ctx).Model(&models.OutboxEvent{}).
+		Select("count(*) AS count, min(created_at) AS oldest").
+		Where("sent_at IS NULL").
+		Scan(&row).Error; err != nil {
+		return 0, time.Time{}, err
+	}
+	if row.Oldest == nil {
+		return row.Count, time.Time{}, nil
+	}
+	return row.Count, *row.Oldest, nil
+}
+
+// DeleteSent deletes up to limit events sent before the given time and
+// returns how many it deleted. Unsent events are never deleted.
+func (r *OutboxRepo) DeleteSent(ctx context.Context, before time.Time, limit int) (int64, error) {
+	ids := r.db.Model(&models.OutboxEvent{}).
+		Select("id").
+		Where("sent_at < ?", before).
+		Order("id").
+		Limit(limit)
+	res := r.db.WithContext(ctx).Where("id IN (?)", ids).Delete(&models.OutboxEvent{})
+	return res.RowsAffected, res.Error
+}
+
+// ChangedSince lists orders with outbox events created at or after since,
+// split by their latest event into changed and deleted ones. The outbox is
+// written in the same transaction as the order, so it doubles as a change
+// log for catching up a cache restored from a snapshot.
+func (r *OutboxRepo) ChangedSince(ctx context.Context, since time.Time) (changed, deleted []string, err error) {
+	var rows []struct {
+		AggregateID string
+		EventType   string
+	}
+	if err := r.db.WithContext(ctx).Model(&models.OutboxEvent{}).
+		Select("DISTINCT ON (aggregate_id) aggregate_id, event_type").
+		Where("created_at >= ?", since).
+		Order("aggregate_id, id DESC").
+		Scan(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+
+	for _, row := range rows {
+		if row.EventType == EventOrderDeleted {
+			deleted = append(deleted, row.AggregateID)
+		} else {
+			changed = append(changed, row.AggregateID)
+		}
+	}
+	return changed, deleted, nil
+}
+
+func enqueueEvent(tx *gorm.DB, eventType, orderUID string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.OutboxEvent{
+		AggregateID: orderUID,
+		EventType:   eventType,
+		Payload:     data,
+	}).Error
+}
+
+func enqueueOrderEvent(tx *gorm.DB, eventType string, order *models.Order) error {
+	return enqueueEvent(tx, eventType, order.OrderUID, order)
+}
+
+func enqueueDeleteEvent(tx *gorm.DB, orderUID string) error {
+	return enqueueEvent(tx, EventOrderDeleted, orderUID, map[string]string{
+		"type":      EventOrderDeleted,
+		"order_uid": orderUID,
+	})
+}
@@ -0,0 +1,82 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sqlmock "github.com/zhashkevych/go-sqlxmock"
+	"testing"
+	"time"
+	"wb-task-L0/pkg/models"
+	"wb-task-L0/pkg/repository"
+)
+
+func TestOutboxRepo_ProcessPending(t *testing.T) {
+	db, mock, err := newGormMock()
+	require.NoError(t, err)
+	mock.MatchExpectationsInOrder(true)
+	repo := repository.NewOutboxRepo(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "outbox" WHERE sent_at IS NULL ORDER BY id LIMIT \$1 FOR UPDATE SKIP LOCKED`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "aggregate_id", "event_type", "payload"}).
+			AddRow(1, "order1", repository.EventOrderAccepted, []byte(`{}`)).
+			AddRow(2, "order2", repository.EventOrderDeleted, []byte(`{}`)))
+	mock.ExpectExec(`UPDATE "outbox" SET "sent_at"=\$1 WHERE id IN \(\$2,\$3\)`).
+		WithArgs(sqlmock.AnyArg(), 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	var published []string
+	n, err := repo.ProcessPending(context.Background(), 2, func(events []models.OutboxEvent) error {
+		for _, e := range events {
+			published = append(published, e.AggregateID)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"order1", "order2"}, published)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutboxRepo_ProcessPending_PublishFails(t *testing.T) {
+	db, mock, err := newGormMock()
+	require.NoError(t, err)
+	mock.MatchExpectationsInOrder(true)
+	repo := repository.NewOutboxRepo(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "outbox" WHERE sent_at IS NULL`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "aggregate_id", "event_type", "payload"}).
+			AddRow(1, "order1", repository.EventOrderAccepted, []byte(`{}`)))
+	mock.ExpectRollback()
+
+	publishErr := errors.New("broker unavailable")
+	n, err := repo.ProcessPending(context.Background(), 10, func([]models.OutboxEvent) error {
+		return publishErr
+	})
+	assert.ErrorIs(t, err, publishErr)
+	assert.Zero(t, n)
+	assert.NoError(t, mock.ExpectationsWereMet(), "the rows are not marked sent")
+}
+
+func TestOutboxRepo_DeleteSent(t *testing.T) {
+	db, mock, err := newGormMock()
+	require.NoError(t, err)
+	repo := repository.NewOutboxRepo(db)
+	before := time.Now().Add(-time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "outbox" WHERE id IN \(SELECT "id" FROM "outbox" WHERE sent_at < \$1 ORDER BY id LIMIT \$2\)`).
+		WithArgs(before, 100).
+		WillReturnResult(sqlmock.NewResult(0, 42))
+	mock.ExpectCommit()
+
+	n, err := repo.DeleteSent(context.Background(), before, 100)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,23 +3,48 @@ package repository
 import (
 	"context"
 	"gorm.io/gorm"
+	"time"
 	"wb-task-L0/pkg/models"
 )
 
 type Order interface {
 	Create(order *models.Order) (string, error)
 	GetAll() ([]models.Order, error)
+	List(ctx context.Context, q OrderQuery) (OrderPage, error)
+	Search(ctx context.Context, term string, limit, offset int) ([]models.Order, bool, error)
+	GetPage(ctx context.Context, after *models.Order, since time.Time, limit int) ([]models.Order, error)
+	Count(ctx context.Context, since time.Time) (int64, error)
 	GetByID(id string) (models.Order, error)
+	GetByIDs(ctx context.Context, ids []string) ([]models.Order, error)
+	TrackOrderIDs(ctx context.Context, track string) ([]string, error)
+	TransactionOrderIDs(ctx context.Context, transaction string) ([]string, error)
+	CustomerOrderIDs(ctx context.Context, customerID string) ([]string, error)
 	Delete(id string) error
+	DeleteVersion(ctx context.Context, id string, version int64, eventTime time.Time) error
+	PruneTombstones(ctx context.Context, before time.Time, limit int) (int64, error)
+	DeleteOrder(ctx context.Context, id string, check func(*models.Order) error) error
 	CreateOrderWithAssociations(context.Context, *models.Order) error
+	UpsertOrderWithAssociations(ctx context.Context, order *models.Order) (UpsertResult, error)
+	PreviewUpsert(ctx context.Context, order *models.Order) (UpsertResult, error)
+	UpdateOrder(ctx context.Context, id string, update func(*models.Order) error) (models.Order, error)
+	UpsertOrdersBatch(ctx context.Context, orders []*models.Order) ([]UpsertResult, error)
+}
+
+type Outbox interface {
+	ProcessPending(ctx context.Context, limit int, publish func([]models.OutboxEvent) error) (int, error)
+	Pending(ctx context.Context) (int64, time.Time, error)
+	DeleteSent(ctx context.Context, before time.Time, limit int) (int64, error)
+	ChangedSince(ctx context.Context, since time.Time) (changed, deleted []string, err error)
 }
 
 type Repository struct {
 	Order
+	Outbox
 }
 
 func NewRepository(db *gorm.DB) *Repository {
 	return &Repository{
-		Order: NewOrderRepo(db),
+		Order:  NewOrderRepo(db),
+		Outbox: NewOutboxRepo(db),
 	}
 }
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"wb-task-L0/pkg/cache"
+	"wb-task-L0/pkg/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+var ErrReloadRunning error = &Error{Kind: ErrConflict, Message: "a full cache reload is already running"}
+
+type CacheStats struct {
+	cache.Stats
+	Warmup cache.WarmupProgress `json:"warmup"`
+	// Rebuild is the last full reload, its error included if it failed.
+	Rebuild cache.WarmupProgress `json:"rebuild"`
+}
+
+type ReloadResult struct {
+	Reloaded int      `json:"reloaded"`
+	Evicted  int      `json:"evicted"`
+	Missing  []string `json:"missing,omitempty"`
+}
+
+// CacheAdminService backs the admin API. It works on the cache of the
+// instance serving the request, other replicas are not touched.
+type CacheAdminService struct {
+	// ctx bounds background reloads, it is cancelled at shutdown
+	ctx       context.Context
+	cache     cache.Cache
+	repo      repository.Order
+	warmer    *cache.Warmer
+	reloading atomic.Bool
+}
+
+func NewCacheAdminService(ctx context.Context, repo repository.Order, cache cache.Cache, warmer *cache.Warmer) *CacheAdminService {
+	return &CacheAdminService{ctx: ctx, cache: cache, repo: repo, warmer: warmer}
+}
+
+func (s *CacheAdminService) Stats() CacheStats {
+	return CacheStats{
+		Stats:   s.cache.Stats(),
+		Warmup:  s.warmer.Progress(),
+		Rebuild: s.warmer.RebuildProgress(),
+	}
+}
+
+// Keys pages through the cached order_uids with the given prefix and
+// returns the page and the total number of matching keys.
+func (s *CacheAdminService) Keys(prefix string, offset, limit int) ([]string, int) {
+	keys := s.cache.Keys(prefix)
+	total := len(keys)
+	if offset >= total {
+		return []string{}, total
+	}
+	return keys[offset:min(offset+limit, total)], total
+}
+
+func (s *CacheAdminService) Evict(id string) bool {
+	if !s.cache.Contains(id) {
+		return false
+	}
+	s.cache.Delete(id)
+	return true
+}
+
+func (s *CacheAdminService) EvictPrefix(prefix string) int {
+	return s.cache.DeletePrefix(prefix)
+}
+
+// Reload refreshes the given orders from Postgres, evicting the ones that no
+// longer exist.
+func (s *CacheAdminService) Reload(ctx context.Context, ids []string) (ReloadResult, error) {
+	orders, err := s.repo.GetByIDs(ctx, ids)
+	if err != nil {
+		return ReloadResult{}, classify(err)
+	}
+
+	res := ReloadResult{Reloaded: len(orders)}
+	found := make(map[string]bool, len(orders))
+	for _, o := range orders {
+		s.cache.SetIfNewer(o)
+		found[o.OrderUID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			s.cache.Delete(id)
+			res.Evicted++
+			res.Missing = append(res.Missing, id)
+		}
+	}
+	return res, nil
+}
+
+// ReloadAll rebuilds the whole cache in the background. It pages through
+// Postgres with the warm-up settings and swaps the result in like
+// LoadFromDB, without holding the full table in memory. Writes made while
+// it runs are kept, see Warmer.Rebuild. Progress and the error, if any, are
+// reported by Stats.
+//
+// Only one admin reload is accepted at a time. The warmer serializes it
+// with the startup warm-up and with resyncs, so it may start after them.
+func (s *CacheAdminService) ReloadAll() error {
+	if !s.reloading.CompareAndSwap(false, true) {
+		return ErrReloadRunning
+	}
+	go func() {
+		defer s.reloading.Store(false)
+		if err := s.warmer.Rebuild(s.ctx); err != nil {
+			logrus.Errorf("full cache reload failed: %s", err.Error())
+			return
+		}
+		logrus.Printf("full cache reload finished with %d orders", s.cache.Len())
+	}()
+	return nil
+}
@@ -0,0 +1,57 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"wb-task-L0/pkg/cache"
+	"wb-task-L0/pkg/models"
+	"wb-task-L0/pkg/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheAdminService_ReloadAll(t *testing.T) {
+	release := make(chan struct{})
+	load := func(ctx context.Context, _ *models.Order, _ time.Time, _ int) ([]models.Order, error) {
+		select {
+		case <-release:
+			return nil, errors.New("db down")
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	c := cache.NewCache(cache.Config{})
+	warmer := cache.NewWarmer(c, cache.WarmupConfig{}, load, nil)
+	svc := service.NewCacheAdminService(context.Background(), nil, c, warmer)
+
+	require.NoError(t, svc.ReloadAll())
+	assert.ErrorIs(t, svc.ReloadAll(), service.ErrConflict, "a second reload is refused while the first runs")
+	close(release)
+
+	require.Eventually(t, func() bool {
+		return svc.Stats().Rebuild.State == cache.WarmupFailed
+	}, time.Second, time.Millisecond)
+	stats := svc.Stats()
+	assert.Equal(t, "db down", stats.Rebuild.Error)
+	assert.Equal(t, cache.WarmupPending, stats.Warmup.State, "a reload does not touch the startup warm-up")
+}
+
+func TestCacheAdminService_ReloadAll_StopsAtShutdown(t *testing.T) {
+	load := func(ctx context.Context, _ *models.Order, _ time.Time, _ int) ([]models.Order, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := cache.NewCache(cache.Config{})
+	svc := service.NewCacheAdminService(ctx, nil, c, cache.NewWarmer(c, cache.WarmupConfig{}, load, nil))
+
+	require.NoError(t, svc.ReloadAll())
+	cancel()
+	require.Eventually(t, func() bool {
+		return svc.Stats().Rebuild.State == cache.WarmupFailed
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, context.Canceled.Error(), svc.Stats().Rebuild.Error)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+	"wb-task-L0/pkg/validator"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Kinds of service errors. Handlers choose the response status with
+// errors.Is(err, ErrNotFound) and so on.
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrValidation  = errors.New("validation failed")
+	ErrUnavailable = errors.New("temporarily unavailable")
+	// ErrInvalidPatch is a merge patch that is not a JSON object or does
+	// not produce an order document.
+	ErrInvalidPatch = errors.New("invalid merge patch")
+	// ErrPreconditionFailed means the order's revision is not one of those
+	// the caller expected, i.e. someone else changed it first.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrCanceled means the caller went away before the request finished.
+	ErrCanceled = errors.New("request canceled")
+)
+
+// Error is a classified failure. Message is written for API clients, Err
+// keeps the cause, which may contain SQL, for the logs. errors.Is and
+// errors.As see both Kind and Err.
+type Error struct {
+	Kind    error
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// classify wraps repository and validation errors into *Error. Errors that
+// are already classified or that it doesn't recognise are returned as they
+// are, the latter end up as internal errors.
+func classify(err error) error {
+	var (
+		classified *Error
+		violations validator.Errors
+		pgErr      *pgconn.PgError
+	)
+	switch {
+	case err == nil, errors.As(err, &classified):
+		return err
+	case errors.As(err, &violations):
+		return &Error{Kind: ErrValidation, Message: "order validation failed", Err: err}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return &Error{Kind: ErrNotFound, Message: "order not found", Err: err}
+	case errors.As(err, &pgErr):
+		return classifyPgError(pgErr.Code, err)
+	case errors.Is(err, context.Canceled):
+		return &Error{Kind: ErrCanceled, Message: "request canceled", Err: err}
+	case unavailable(err):
+		return &Error{Kind: ErrUnavailable, Message: "database is unavailable", Err: err}
+	}
+	return err
+}
+
+// classifyPgError maps Postgres SQLSTATE codes, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+func classifyPgError(code string, err error) error {
+	switch {
+	case code == "23505":
+		return &Error{Kind: ErrConflict, Message: "order already exists", Err: err}
+	case code == "40001", code == "40P01", code == "55P03":
+		return &Error{Kind: ErrConflict, Message: "order is being changed concurrently, retry the request", Err: err}
+	case strings.HasPrefix(code, "22"), strings.HasPrefix(code, "23"):
+		return &Error{Kind: ErrValidation, Message: "order data is rejected by the database", Err: err}
+	case strings.HasPrefix(code, "08"), strings.HasPrefix(code, "53"), strings.HasPrefix(code, "57P"), code == "57014":
+		return &Error{Kind: ErrUnavailable, Message: "database is unavailable", Err: err}
+	}
+	return err
+}
+
+func unavailable(err error) bool {
+	var (
+		connErr *pgconn.ConnectError
+		netErr  net.Error
+	)
+	return errors.As(err, &connErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		pgconn.Timeout(err)
+}
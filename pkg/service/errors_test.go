@@ -0,0 +1,61 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"wb-task-L0/pkg/cache"
+	"wb-task-L0/pkg/invalidation"
+	"wb-task-L0/pkg/repository"
+	"wb-task-L0/pkg/service"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type failingRepo struct {
+	repository.Order
+	err error
+}
+
+func (r *failingRepo) List(context.Context, repository.OrderQuery) (repository.OrderPage, error) {
+	return repository.OrderPage{}, r.err
+}
+
+func TestOrderService_ClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantKind error
+	}{
+		{"not found", gorm.ErrRecordNotFound, service.ErrNotFound},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, service.ErrConflict},
+		{"deadlock", fmt.Errorf("save: %w", &pgconn.PgError{Code: "40P01"}), service.ErrConflict},
+		{"value too long", &pgconn.PgError{Code: "22001"}, service.ErrValidation},
+		{"connection lost", &pgconn.PgError{Code: "08006"}, service.ErrUnavailable},
+		{"timeout", context.DeadlineExceeded, service.ErrUnavailable},
+		{"client gone", fmt.Errorf("list: %w", context.Canceled), service.ErrCanceled},
+		{"unknown", errors.New("boom"), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := service.NewOrderService(&failingRepo{err: tt.err}, cache.NewCache(cache.Config{}), invalidation.Nop{})
+
+			_, err := svc.List(context.Background(), repository.OrderQuery{})
+			assert.ErrorIs(t, err, tt.err, "the cause is kept for the logs")
+
+			var classified *service.Error
+			if tt.wantKind == nil {
+				assert.False(t, errors.As(err, &classified))
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantKind)
+			if assert.ErrorAs(t, err, &classified) {
+				assert.NotContains(t, classified.Message, "SQLSTATE")
+			}
+		})
+	}
+}
@@ -5,8 +5,11 @@
 package mock_service
 
 import (
+	context "context"
 	reflect "reflect"
 	models "wb-task-L0/pkg/models"
+	repository "wb-task-L0/pkg/repository"
+	service "wb-task-L0/pkg/service"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -35,32 +38,46 @@ func (m *MockOrder) EXPECT() *MockOrderMockRecorder {
 }
 
 // Create mocks base method.
-func (m *MockOrder) Create(arg0 *models.Order) (*models.Order, error) {
+func (m *MockOrder) Create(order *models.Order) (*models.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", arg0)
+	ret := m.ctrl.Call(m, "Create", order)
 	ret0, _ := ret[0].(*models.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
-func (mr *MockOrderMockRecorder) Create(arg0 interface{}) *gomock.Call {
+func (mr *MockOrderMockRecorder) Create(order interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockOrder)(nil).Create), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockOrder)(nil).Create), order)
+}
+
+// CreateOrderWithAssociations mocks base method.
+func (m *MockOrder) CreateOrderWithAssociations(arg0 context.Context, arg1 *models.Order) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOrderWithAssociations", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOrderWithAssociations indicates an expected call of CreateOrderWithAssociations.
+func (mr *MockOrderMockRecorder) CreateOrderWithAssociations(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrderWithAssociations", reflect.TypeOf((*MockOrder)(nil).CreateOrderWithAssociations), arg0, arg1)
 }
 
 // Delete mocks base method.
-func (m *MockOrder) Delete(id string) error {
+func (m *MockOrder) Delete(ctx context.Context, id string, ifMatch []int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", id)
+	ret := m.ctrl.Call(m, "Delete", ctx, id, ifMatch)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete.
-func (mr *MockOrderMockRecorder) Delete(id interface{}) *gomock.Call {
+func (mr *MockOrderMockRecorder) Delete(ctx, id, ifMatch interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockOrder)(nil).Delete), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockOrder)(nil).Delete), ctx, id, ifMatch)
 }
 
 // GetAll mocks base method.
@@ -78,6 +95,21 @@ func (mr *MockOrderMockRecorder) GetAll() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockOrder)(nil).GetAll))
 }
 
+// GetByCustomer mocks base method.
+func (m *MockOrder) GetByCustomer(ctx context.Context, customerID string) ([]models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByCustomer", ctx, customerID)
+	ret0, _ := ret[0].([]models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByCustomer indicates an expected call of GetByCustomer.
+func (mr *MockOrderMockRecorder) GetByCustomer(ctx, customerID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByCustomer", reflect.TypeOf((*MockOrder)(nil).GetByCustomer), ctx, customerID)
+}
+
 // GetByID mocks base method.
 func (m *MockOrder) GetByID(id string) (models.Order, error) {
 	m.ctrl.T.Helper()
@@ -92,3 +124,203 @@ func (mr *MockOrderMockRecorder) GetByID(id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByID", reflect.TypeOf((*MockOrder)(nil).GetByID), id)
 }
+
+// GetByTrackNumber mocks base method.
+func (m *MockOrder) GetByTrackNumber(ctx context.Context, track string) ([]models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByTrackNumber", ctx, track)
+	ret0, _ := ret[0].([]models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByTrackNumber indicates an expected call of GetByTrackNumber.
+func (mr *MockOrderMockRecorder) GetByTrackNumber(ctx, track interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByTrackNumber", reflect.TypeOf((*MockOrder)(nil).GetByTrackNumber), ctx, track)
+}
+
+// GetByTransaction mocks base method.
+func (m *MockOrder) GetByTransaction(ctx context.Context, transaction string) ([]models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByTransaction", ctx, transaction)
+	ret0, _ := ret[0].([]models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByTransaction indicates an expected call of GetByTransaction.
+func (mr *MockOrderMockRecorder) GetByTransaction(ctx, transaction interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByTransaction", reflect.TypeOf((*MockOrder)(nil).GetByTransaction), ctx, transaction)
+}
+
+// List mocks base method.
+func (m *MockOrder) List(ctx context.Context, q repository.OrderQuery) (repository.OrderPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, q)
+	ret0, _ := ret[0].(repository.OrderPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockOrderMockRecorder) List(ctx, q interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockOrder)(nil).List), ctx, q)
+}
+
+// Patch mocks base method.
+func (m *MockOrder) Patch(ctx context.Context, id string, patch []byte, ifMatch []int64) (models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Patch", ctx, id, patch, ifMatch)
+	ret0, _ := ret[0].(models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Patch indicates an expected call of Patch.
+func (mr *MockOrderMockRecorder) Patch(ctx, id, patch, ifMatch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Patch", reflect.TypeOf((*MockOrder)(nil).Patch), ctx, id, patch, ifMatch)
+}
+
+// Replace mocks base method.
+func (m *MockOrder) Replace(ctx context.Context, id string, order models.Order, ifMatch []int64) (models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replace", ctx, id, order, ifMatch)
+	ret0, _ := ret[0].(models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Replace indicates an expected call of Replace.
+func (mr *MockOrderMockRecorder) Replace(ctx, id, order, ifMatch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replace", reflect.TypeOf((*MockOrder)(nil).Replace), ctx, id, order, ifMatch)
+}
+
+// Search mocks base method.
+func (m *MockOrder) Search(ctx context.Context, term string, limit int, offset int) ([]models.Order, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", ctx, term, limit, offset)
+	ret0, _ := ret[0].([]models.Order)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockOrderMockRecorder) Search(ctx, term, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockOrder)(nil).Search), ctx, term, limit, offset)
+}
+
+// MockCacheAdmin is a mock of CacheAdmin interface.
+type MockCacheAdmin struct {
+	ctrl     *gomock.Controller
+	recorder *MockCacheAdminMockRecorder
+}
+
+// MockCacheAdminMockRecorder is the mock recorder for MockCacheAdmin.
+type MockCacheAdminMockRecorder struct {
+	mock *MockCacheAdmin
+}
+
+// NewMockCacheAdmin creates a new mock instance.
+func NewMockCacheAdmin(ctrl *gomock.Controller) *MockCacheAdmin {
+	mock := &MockCacheAdmin{ctrl: ctrl}
+	mock.recorder = &MockCacheAdminMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCacheAdmin) EXPECT() *MockCacheAdminMockRecorder {
+	return m.recorder
+}
+
+// Evict mocks base method.
+func (m *MockCacheAdmin) Evict(id string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Evict", id)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Evict indicates an expected call of Evict.
+func (mr *MockCacheAdminMockRecorder) Evict(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Evict", reflect.TypeOf((*MockCacheAdmin)(nil).Evict), id)
+}
+
+// EvictPrefix mocks base method.
+func (m *MockCacheAdmin) EvictPrefix(prefix string) int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EvictPrefix", prefix)
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// EvictPrefix indicates an expected call of EvictPrefix.
+func (mr *MockCacheAdminMockRecorder) EvictPrefix(prefix interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EvictPrefix", reflect.TypeOf((*MockCacheAdmin)(nil).EvictPrefix), prefix)
+}
+
+// Keys mocks base method.
+func (m *MockCacheAdmin) Keys(prefix string, offset int, limit int) ([]string, int) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Keys", prefix, offset, limit)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(int)
+	return ret0, ret1
+}
+
+// Keys indicates an expected call of Keys.
+func (mr *MockCacheAdminMockRecorder) Keys(prefix, offset, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Keys", reflect.TypeOf((*MockCacheAdmin)(nil).Keys), prefix, offset, limit)
+}
+
+// Reload mocks base method.
+func (m *MockCacheAdmin) Reload(ctx context.Context, ids []string) (service.ReloadResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reload", ctx, ids)
+	ret0, _ := ret[0].(service.ReloadResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reload indicates an expected call of Reload.
+func (mr *MockCacheAdminMockRecorder) Reload(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reload", reflect.TypeOf((*MockCacheAdmin)(nil).Reload), ctx, ids)
+}
+
+// ReloadAll mocks base method.
+func (m *MockCacheAdmin) ReloadAll() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReloadAll")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReloadAll indicates an expected call of ReloadAll.
+func (mr *MockCacheAdminMockRecorder) ReloadAll() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReloadAll", reflect.TypeOf((*MockCacheAdmin)(nil).ReloadAll))
+}
+
+// Stats mocks base method.
+func (m *MockCacheAdmin) Stats() service.CacheStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(service.CacheStats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockCacheAdminMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockCacheAdmin)(nil).Stats))
+}
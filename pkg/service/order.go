@@ -2,68 +2,227 @@ package service
 
 import (
 	"context"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+	"slices"
 	"wb-task-L0/pkg/cache"
+	"wb-task-L0/pkg/invalidation"
+	"wb-task-L0/pkg/metrics"
 	"wb-task-L0/pkg/models"
 	"wb-task-L0/pkg/repository"
 )
 
 type OrderService struct {
-	repo  repository.Order
-	cache *cache.OrderCache
+	repo          repository.Order
+	cache         cache.Cache
+	invalidations invalidation.Publisher
+	loads         singleflight.Group
 }
 
-func NewOrderService(repo repository.Order, cache *cache.OrderCache) *OrderService {
+func NewOrderService(repo repository.Order, cache cache.Cache, invalidations invalidation.Publisher) *OrderService {
 	return &OrderService{
-		repo:  repo,
-		cache: cache,
+		repo:          repo,
+		cache:         cache,
+		invalidations: invalidations,
 	}
 }
 
 func (s *OrderService) Create(order *models.Order) (*models.Order, error) {
 	uid, err := s.repo.Create(order)
 	if err != nil {
-		return nil, err
+		return nil, classify(err)
 	}
 
 	order.OrderUID = uid
 	s.cache.Set(*order)
+	s.invalidate(context.Background(), invalidation.OpRefresh, uid)
 
 	return order, nil
 }
 
 func (s *OrderService) CreateOrderWithAssociations(ctx context.Context, order *models.Order) error {
 	if err := s.repo.CreateOrderWithAssociations(ctx, order); err != nil {
-		return err
+		return classify(err)
 	}
 	s.cache.Set(*order)
+	s.invalidate(ctx, invalidation.OpRefresh, order.OrderUID)
 	return nil
 }
 
 func (s *OrderService) GetAll() ([]models.Order, error) {
-	return s.repo.GetAll()
+	orders, err := s.repo.GetAll()
+	return orders, classify(err)
+}
+
+// List is served by Postgres, the cache cannot answer filtered or ordered
+// queries.
+func (s *OrderService) List(ctx context.Context, q repository.OrderQuery) (repository.OrderPage, error) {
+	page, err := s.repo.List(ctx, q)
+	return page, classify(err)
 }
 
+// Search ranks orders by how well their delivery details or item names
+// match term. Like List it always goes to Postgres.
+func (s *OrderService) Search(ctx context.Context, term string, limit, offset int) ([]models.Order, bool, error) {
+	orders, more, err := s.repo.Search(ctx, term, limit, offset)
+	return orders, more, classify(err)
+}
+
+// GetByID serves from the cache. Concurrent misses for the same id share one
+// database load, and ids that were not found are remembered for a short
+// while so that lookups of missing orders don't all reach Postgres.
 func (s *OrderService) GetByID(id string) (models.Order, error) {
 	if order, ok := s.cache.Get(id); ok {
 		return order, nil
 	}
+	if s.cache.IsMissing(id) {
+		return models.Order{}, classify(gorm.ErrRecordNotFound)
+	}
+
+	v, err, shared := s.loads.Do(id, func() (any, error) {
+		order, err := s.repo.GetByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				s.cache.Delete(id)
+				s.cache.SetMissing(id)
+			}
+			return nil, err
+		}
+		s.cache.SetIfNewer(order)
+		return order, nil
+	})
+	if shared {
+		metrics.CacheLoads.Add("shared", 1)
+	} else {
+		metrics.CacheLoads.Add("db", 1)
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.Order{}, classify(err)
+	}
+	if err != nil {
+		// stale-while-revalidate: the refresher retries once the DB is back
+		if stale, ok := s.cache.GetStale(id); ok {
+			logrus.Warnf("serving stale order %s: %s", id, err.Error())
+			return stale, nil
+		}
+		return models.Order{}, classify(err)
+	}
+
+	return v.(models.Order), nil
+}
+
+// GetByTrackNumber, GetByTransaction and GetByCustomer cannot trust a cache
+// hit, the cache may hold only some of the matching orders. They list the
+// ids from an index and load only the orders the cache is missing, newest
+// first. If the database is down they serve what the cache has.
+func (s *OrderService) GetByTrackNumber(ctx context.Context, track string) ([]models.Order, error) {
+	ids, err := s.repo.TrackOrderIDs(ctx, track)
+	if err != nil {
+		return s.cachedOnError(err, "track number "+track, s.cache.GetByTrackNumber(track))
+	}
+	return s.getByIDs(ctx, ids)
+}
+
+func (s *OrderService) GetByTransaction(ctx context.Context, transaction string) ([]models.Order, error) {
+	ids, err := s.repo.TransactionOrderIDs(ctx, transaction)
+	if err != nil {
+		return s.cachedOnError(err, "transaction "+transaction, s.cache.GetByTransaction(transaction))
+	}
+	return s.getByIDs(ctx, ids)
+}
 
-	order, err := s.repo.GetByID(id)
+func (s *OrderService) GetByCustomer(ctx context.Context, customerID string) ([]models.Order, error) {
+	ids, err := s.repo.CustomerOrderIDs(ctx, customerID)
 	if err != nil {
-		return models.Order{}, err
+		return s.cachedOnError(err, "customer "+customerID, s.cache.GetByCustomer(customerID))
+	}
+	return s.getByIDs(ctx, ids)
+}
+
+// getByIDs returns the orders in the order of ids, taking what it can from
+// the cache. Orders deleted since ids was listed are left out.
+func (s *OrderService) getByIDs(ctx context.Context, ids []string) ([]models.Order, error) {
+	found := make(map[string]models.Order, len(ids))
+	var missing []string
+	for _, id := range ids {
+		if order, ok := s.cache.Get(id); ok {
+			found[id] = order
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	loaded, err := s.loadAndCache(s.repo.GetByIDs(ctx, missing))
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range loaded {
+		found[o.OrderUID] = o
+	}
+
+	orders := make([]models.Order, 0, len(ids))
+	for _, id := range ids {
+		if order, ok := found[id]; ok {
+			orders = append(orders, order)
+		}
 	}
+	return orders, nil
+}
 
-	s.cache.Set(order)
+// cachedOnError serves the cached matches, newest first, when the id lookup
+// failed.
+func (s *OrderService) cachedOnError(err error, what string, cached []models.Order) ([]models.Order, error) {
+	if len(cached) == 0 {
+		return nil, classify(err)
+	}
+	logrus.Warnf("serving cached orders of %s: %s", what, err.Error())
+	slices.SortFunc(cached, func(a, b models.Order) int {
+		return b.DateCreated.Compare(a.DateCreated)
+	})
+	return cached, nil
+}
 
-	return s.repo.GetByID(id)
+func (s *OrderService) loadAndCache(orders []models.Order, err error) ([]models.Order, error) {
+	if err != nil {
+		return nil, classify(err)
+	}
+	for _, o := range orders {
+		s.cache.SetIfNewer(o)
+	}
+	return orders, nil
 }
 
-func (s *OrderService) Delete(id string) error {
-	if err := s.repo.Delete(id); err != nil {
-		return err
+// Delete removes the order. Without ifMatch deleting a missing order is not
+// an error; with it the order has to exist at one of the given revisions.
+func (s *OrderService) Delete(ctx context.Context, id string, ifMatch []int64) error {
+	var err error
+	if ifMatch == nil {
+		err = s.repo.Delete(id)
+	} else {
+		err = s.repo.DeleteOrder(ctx, id, func(current *models.Order) error {
+			return checkRevision(ifMatch, current.Revision)
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = errRevisionMismatch
+		}
+	}
+	if err != nil {
+		return classify(err)
 	}
 
 	s.cache.Delete(id)
+	s.invalidate(ctx, invalidation.OpEvict, id)
 
 	return nil
 }
+
+// invalidate tells other instances about a write. The write is already
+// committed, so a failure is only logged; their entries expire with the TTL.
+func (s *OrderService) invalidate(ctx context.Context, op invalidation.Op, id string) {
+	if err := s.invalidations.Publish(ctx, op, id); err != nil {
+		logrus.Warnf("failed to publish cache invalidation for order %s: %s", id, err.Error())
+	}
+}
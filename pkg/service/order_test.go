@@ -0,0 +1,162 @@
+package service_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"wb-task-L0/pkg/cache"
+	"wb-task-L0/pkg/invalidation"
+	"wb-task-L0/pkg/models"
+	"wb-task-L0/pkg/repository"
+	"wb-task-L0/pkg/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// slowRepo answers GetByID only after release is closed.
+type slowRepo struct {
+	repository.Order
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (r *slowRepo) GetByID(id string) (models.Order, error) {
+	r.calls.Add(1)
+	<-r.release
+	if id == "missing" {
+		return models.Order{}, gorm.ErrRecordNotFound
+	}
+	return models.Order{OrderUID: id}, nil
+}
+
+func TestOrderService_GetByID_Coalesces(t *testing.T) {
+	repo := &slowRepo{release: make(chan struct{})}
+	svc := service.NewOrderService(repo, cache.NewCache(cache.Config{}), invalidation.Nop{})
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			order, err := svc.GetByID("a")
+			if err == nil && order.OrderUID != "a" {
+				err = assert.AnError
+			}
+			errs <- err
+		}()
+	}
+
+	require.Eventually(t, func() bool { return repo.calls.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond) // let the rest pile up behind the first load
+	close(repo.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), repo.calls.Load())
+}
+
+func TestOrderService_GetByID_NegativeCache(t *testing.T) {
+	repo := &slowRepo{release: make(chan struct{})}
+	close(repo.release)
+	c := cache.NewCache(cache.Config{NegativeTTL: time.Minute})
+	svc := service.NewOrderService(repo, c, invalidation.Nop{})
+
+	for i := 0; i < 3; i++ {
+		_, err := svc.GetByID("missing")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	}
+	assert.Equal(t, int32(1), repo.calls.Load(), "repeated misses are answered from the negative cache")
+
+	c.Set(models.Order{OrderUID: "missing"})
+	order, err := svc.GetByID("missing")
+	require.NoError(t, err)
+	assert.Equal(t, "missing", order.OrderUID, "a later Set clears the negative entry")
+}
+
+// racingRepo reads revision 1 while a writer caches revision 2.
+type racingRepo struct {
+	repository.Order
+	cache cache.Cache
+}
+
+func (r *racingRepo) GetByID(id string) (models.Order, error) {
+	r.cache.Set(models.Order{OrderUID: id, Revision: 2})
+	return models.Order{OrderUID: id, Revision: 1}, nil
+}
+
+func TestOrderService_GetByID_KeepsNewerWrite(t *testing.T) {
+	c := cache.NewCache(cache.Config{})
+	svc := service.NewOrderService(&racingRepo{cache: c}, c, invalidation.Nop{})
+
+	_, err := svc.GetByID("a")
+	require.NoError(t, err)
+	got, ok := c.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, int64(2), got.Revision, "the slower read does not replace the write")
+}
+
+// lookupRepo lists ids newest first and returns GetByIDs in its own order,
+// as SQL IN does.
+type lookupRepo struct {
+	repository.Order
+	ids     []string
+	err     error
+	fetched []string
+}
+
+func (r *lookupRepo) TrackOrderIDs(context.Context, string) ([]string, error) {
+	return r.ids, r.err
+}
+
+func (r *lookupRepo) GetByIDs(_ context.Context, ids []string) ([]models.Order, error) {
+	r.fetched = ids
+	orders := make([]models.Order, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		orders = append(orders, models.Order{OrderUID: ids[i], TrackNumber: "track"})
+	}
+	return orders, nil
+}
+
+func TestOrderService_GetByTrackNumber_PartialCache(t *testing.T) {
+	repo := &lookupRepo{ids: []string{"c", "b", "a"}}
+	c := cache.NewCache(cache.Config{})
+	c.Set(models.Order{OrderUID: "b", TrackNumber: "track"})
+	svc := service.NewOrderService(repo, c, invalidation.Nop{})
+
+	orders, err := svc.GetByTrackNumber(context.Background(), "track")
+	require.NoError(t, err)
+
+	var uids []string
+	for _, o := range orders {
+		uids = append(uids, o.OrderUID)
+	}
+	assert.Equal(t, []string{"c", "b", "a"}, uids, "a partial cache hit is completed, newest first")
+	assert.Equal(t, []string{"c", "a"}, repo.fetched, "only the orders missing from the cache are loaded")
+}
+
+func TestOrderService_GetByTrackNumber_DatabaseDown(t *testing.T) {
+	repo := &lookupRepo{err: driver.ErrBadConn}
+	c := cache.NewCache(cache.Config{})
+	now := time.Now()
+	c.Set(models.Order{OrderUID: "old", TrackNumber: "track", DateCreated: now.Add(-time.Hour)})
+	c.Set(models.Order{OrderUID: "new", TrackNumber: "track", DateCreated: now})
+	svc := service.NewOrderService(repo, c, invalidation.Nop{})
+
+	orders, err := svc.GetByTrackNumber(context.Background(), "track")
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	assert.Equal(t, "new", orders[0].OrderUID)
+
+	_, err = svc.GetByTrackNumber(context.Background(), "other")
+	assert.ErrorIs(t, err, service.ErrUnavailable)
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"wb-task-L0/pkg/cache"
+	"wb-task-L0/pkg/invalidation"
 	"wb-task-L0/pkg/models"
 	"wb-task-L0/pkg/repository"
 )
@@ -13,16 +14,36 @@ type Order interface {
 	Create(order *models.Order) (*models.Order, error)
 	GetByID(id string) (models.Order, error)
 	GetAll() ([]models.Order, error)
-	Delete(id string) error
+	List(ctx context.Context, q repository.OrderQuery) (repository.OrderPage, error)
+	Search(ctx context.Context, term string, limit, offset int) ([]models.Order, bool, error)
+	GetByTrackNumber(ctx context.Context, track string) ([]models.Order, error)
+	GetByCustomer(ctx context.Context, customerID string) ([]models.Order, error)
+	GetByTransaction(ctx context.Context, transaction string) ([]models.Order, error)
+	Replace(ctx context.Context, id string, order models.Order, ifMatch []int64) (models.Order, error)
+	Patch(ctx context.Context, id string, patch []byte, ifMatch []int64) (models.Order, error)
+	Delete(ctx context.Context, id string, ifMatch []int64) error
 	CreateOrderWithAssociations(context.Context, *models.Order) error
 }
 
+type CacheAdmin interface {
+	Stats() CacheStats
+	Keys(prefix string, offset, limit int) ([]string, int)
+	Evict(id string) bool
+	EvictPrefix(prefix string) int
+	Reload(ctx context.Context, ids []string) (ReloadResult, error)
+	ReloadAll() error
+}
+
 type Service struct {
 	Order
+	CacheAdmin
 }
 
-func NewService(repos *repository.Repository, cache *cache.OrderCache) *Service {
+// NewService wires the services. ctx bounds their background work and
+// should be cancelled at shutdown.
+func NewService(ctx context.Context, repos *repository.Repository, cache cache.Cache, warmer *cache.Warmer, invalidations invalidation.Publisher) *Service {
 	return &Service{
-		Order: NewOrderService(repos.Order, cache),
+		Order:      NewOrderService(repos.Order, cache, invalidations),
+		CacheAdmin: NewCacheAdminService(ctx, repos.Order, cache, warmer),
 	}
 }
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"wb-task-L0/pkg/invalidation"
+	"wb-task-L0/pkg/models"
+	"wb-task-L0/pkg/validator"
+)
+
+var errRevisionMismatch error = &Error{Kind: ErrPreconditionFailed, Message: "order revision does not match"}
+
+// checkRevision accepts any revision when ifMatch is nil. A non-nil empty
+// ifMatch matches nothing.
+func checkRevision(ifMatch []int64, revision int64) error {
+	if ifMatch == nil || slices.Contains(ifMatch, revision) {
+		return nil
+	}
+	return errRevisionMismatch
+}
+
+// Replace overwrites the order with id as a whole. An empty order_uid is
+// taken from id. With a non-nil ifMatch the stored revision must be one of
+// its values, as with Patch and Delete.
+func (s *OrderService) Replace(ctx context.Context, id string, order models.Order, ifMatch []int64) (models.Order, error) {
+	if order.OrderUID == "" {
+		order.OrderUID = id
+	}
+	return s.update(ctx, id, ifMatch, func(current *models.Order) error {
+		*current = order.Clone()
+		return nil
+	})
+}
+
+// Patch applies a JSON Merge Patch (RFC 7396) to the stored order. Objects
+// such as delivery and payment are merged field by field, items is an array
+// and is replaced as a whole, null removes a field.
+func (s *OrderService) Patch(ctx context.Context, id string, patch []byte, ifMatch []int64) (models.Order, error) {
+	return s.update(ctx, id, ifMatch, func(current *models.Order) error {
+		doc, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+		merged, err := mergePatch(doc, patch)
+		if err != nil {
+			return err
+		}
+
+		var patched models.Order
+		if err := json.Unmarshal(merged, &patched); err != nil {
+			return &Error{Kind: ErrInvalidPatch, Message: "patched document is not a valid order", Err: err}
+		}
+		*current = patched
+		return nil
+	})
+}
+
+// update runs the change inside the repository transaction, so the order
+// can't change between reading and writing it, then puts the result into
+// the cache and tells the other instances.
+func (s *OrderService) update(ctx context.Context, id string, ifMatch []int64, change func(*models.Order) error) (models.Order, error) {
+	order, err := s.repo.UpdateOrder(ctx, id, func(current *models.Order) error {
+		if err := checkRevision(ifMatch, current.Revision); err != nil {
+			return err
+		}
+		if err := change(current); err != nil {
+			return err
+		}
+		if current.OrderUID != id {
+			return validator.Errors{{Field: "order_uid", Message: "is immutable"}}
+		}
+		return validator.ValidateOrder(current)
+	})
+	if err != nil {
+		return models.Order{}, classify(err)
+	}
+
+	s.cache.Set(order)
+	s.invalidate(ctx, invalidation.OpRefresh, id)
+	return order, nil
+}
+
+// mergePatch implements RFC 7396 on raw JSON documents.
+func mergePatch(doc, patch []byte) ([]byte, error) {
+	p, err := decodeObject(patch)
+	if err != nil || p == nil {
+		return nil, &Error{Kind: ErrInvalidPatch, Message: "patch must be a JSON object", Err: err}
+	}
+	target, err := decodeObject(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergeObject(target, p))
+}
+
+// decodeObject keeps numbers as json.Number, ids like nm_id and payment_dt
+// must not go through float64.
+func decodeObject(data []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var obj map[string]any
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func mergeObject(target, patch map[string]any) map[string]any {
+	if target == nil {
+		target = make(map[string]any, len(patch))
+	}
+	for k, v := range patch {
+		switch v := v.(type) {
+		case nil:
+			delete(target, k)
+		case map[string]any:
+			sub, _ := target[k].(map[string]any)
+			target[k] = mergeObject(sub, v)
+		default:
+			target[k] = v
+		}
+	}
+	return target
+}
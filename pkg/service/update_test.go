@@ -0,0 +1,206 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"wb-task-L0/pkg/cache"
+	"wb-task-L0/pkg/invalidation"
+	"wb-task-L0/pkg/models"
+	"wb-task-L0/pkg/repository"
+	"wb-task-L0/pkg/service"
+	"wb-task-L0/pkg/validator"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// updateRepo keeps one order and applies UpdateOrder the way the real
+// repository does: the change only sticks when update succeeds.
+type updateRepo struct {
+	repository.Order
+	stored models.Order
+}
+
+func (r *updateRepo) UpdateOrder(_ context.Context, id string, update func(*models.Order) error) (models.Order, error) {
+	if id != r.stored.OrderUID {
+		return models.Order{}, gorm.ErrRecordNotFound
+	}
+	order := r.stored.Clone()
+	if err := update(&order); err != nil {
+		return models.Order{}, err
+	}
+	order.Revision = r.stored.Revision + 1
+	r.stored = order
+	return order, nil
+}
+
+func (r *updateRepo) DeleteOrder(_ context.Context, id string, check func(*models.Order) error) error {
+	if id != r.stored.OrderUID {
+		return gorm.ErrRecordNotFound
+	}
+	if err := check(&r.stored); err != nil {
+		return err
+	}
+	r.stored = models.Order{}
+	return nil
+}
+
+func storedOrder() models.Order {
+	return models.Order{
+		OrderUID:    "order123",
+		Revision:    3,
+		TrackNumber: "track456",
+		Entry:       "WBIL",
+		Locale:      "en",
+		CustomerID:  "cust1",
+		DateCreated: time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC),
+		Delivery: models.Delivery{
+			DeliveryID: "del1_order123",
+			OrderUID:   "order123",
+			Name:       "John",
+			Phone:      "+1234567890",
+			Zip:        "123456",
+			City:       "Moscow",
+			Address:    "Red Square, 1",
+			Email:      "john@example.com",
+		},
+		Payment: models.Payment{
+			PaymentID:    "pay1_order123",
+			OrderUID:     "order123",
+			Transaction:  "tx123",
+			Currency:     "RUB",
+			Provider:     "wbpay",
+			Amount:       700,
+			PaymentDt:    1735728000,
+			DeliveryCost: 200,
+			GoodsTotal:   500,
+		},
+		Items: []models.Item{
+			{ItemID: "it1_order123", OrderUID: "order123", ChrtID: 9934930, NmID: 9007199254740993,
+				TrackNumber: "track456", Price: 500, Name: "item1", TotalPrice: 500},
+		},
+	}
+}
+
+func TestOrderService_Patch(t *testing.T) {
+	repo := &updateRepo{stored: storedOrder()}
+	c := cache.NewCache(cache.Config{})
+	svc := service.NewOrderService(repo, c, invalidation.Nop{})
+
+	order, err := svc.Patch(context.Background(), "order123", []byte(`{"delivery":{"address":"Ploshad Mira 15"}}`), nil)
+	require.NoError(t, err)
+
+	want := storedOrder()
+	want.Delivery.Address = "Ploshad Mira 15"
+	want.Revision++
+	assert.Equal(t, want, order, "only the address changes, large ids survive the round trip")
+
+	cached, ok := c.Get("order123")
+	require.True(t, ok)
+	assert.Equal(t, "Ploshad Mira 15", cached.Delivery.Address)
+}
+
+func TestOrderService_Patch_Rejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		wantErr func(t *testing.T, err error)
+	}{
+		{
+			name:  "order_uid change",
+			patch: `{"order_uid":"other"}`,
+			wantErr: func(t *testing.T, err error) {
+				var verrs validator.Errors
+				require.True(t, errors.As(err, &verrs))
+				assert.Equal(t, "order_uid", verrs[0].Field)
+			},
+		},
+		{
+			name:  "invalid result",
+			patch: `{"delivery":{"email":"nope"}}`,
+			wantErr: func(t *testing.T, err error) {
+				var verrs validator.Errors
+				assert.True(t, errors.As(err, &verrs))
+			},
+		},
+		{
+			name:  "not an object",
+			patch: `[1,2]`,
+			wantErr: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, service.ErrInvalidPatch)
+			},
+		},
+		{
+			name:  "wrong type",
+			patch: `{"items":"none"}`,
+			wantErr: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, service.ErrInvalidPatch)
+				var classified *service.Error
+				if assert.ErrorAs(t, err, &classified) {
+					assert.NotContains(t, classified.Message, "json:", "decoder text stays in the cause")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &updateRepo{stored: storedOrder()}
+			svc := service.NewOrderService(repo, cache.NewCache(cache.Config{}), invalidation.Nop{})
+
+			_, err := svc.Patch(context.Background(), "order123", []byte(tt.patch), nil)
+			require.Error(t, err)
+			tt.wantErr(t, err)
+			assert.Equal(t, storedOrder(), repo.stored, "a rejected patch changes nothing")
+		})
+	}
+}
+
+func TestOrderService_Replace(t *testing.T) {
+	repo := &updateRepo{stored: storedOrder()}
+	svc := service.NewOrderService(repo, cache.NewCache(cache.Config{}), invalidation.Nop{})
+
+	input := storedOrder()
+	input.OrderUID = ""
+	input.Items = append(input.Items, models.Item{ItemID: "it2", ChrtID: 2, TrackNumber: "track456", Price: 100, Name: "item2", TotalPrice: 100})
+	input.Payment.GoodsTotal = 600
+	input.Payment.Amount = 800
+
+	order, err := svc.Replace(context.Background(), "order123", input, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "order123", order.OrderUID)
+	assert.Len(t, repo.stored.Items, 2)
+
+	_, err = svc.Replace(context.Background(), "missing", storedOrder(), nil)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestOrderService_IfMatch(t *testing.T) {
+	repo := &updateRepo{stored: storedOrder()}
+	c := cache.NewCache(cache.Config{})
+	svc := service.NewOrderService(repo, c, invalidation.Nop{})
+	ctx := context.Background()
+	patch := []byte(`{"delivery":{"city":"Kazan"}}`)
+
+	_, err := svc.Patch(ctx, "order123", patch, []int64{2})
+	assert.ErrorIs(t, err, service.ErrPreconditionFailed)
+	_, err = svc.Patch(ctx, "order123", patch, []int64{})
+	assert.ErrorIs(t, err, service.ErrPreconditionFailed, "an empty list matches nothing")
+	assert.Equal(t, storedOrder(), repo.stored)
+
+	order, err := svc.Patch(ctx, "order123", patch, []int64{2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), order.Revision)
+
+	_, err = svc.Replace(ctx, "order123", storedOrder(), []int64{3})
+	assert.ErrorIs(t, err, service.ErrPreconditionFailed, "the patch moved the order to revision 4")
+
+	assert.ErrorIs(t, svc.Delete(ctx, "order123", []int64{3}), service.ErrPreconditionFailed)
+	require.NoError(t, svc.Delete(ctx, "order123", []int64{4}))
+	assert.False(t, c.Contains("order123"))
+	assert.ErrorIs(t, svc.Delete(ctx, "order123", []int64{4}), service.ErrPreconditionFailed,
+		"If-Match on a missing order fails")
+}
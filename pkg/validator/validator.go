@@ -0,0 +1,181 @@
+package validator
+
+import (
+	"fmt"
+	"math"
+	"net/mail"
+	"regexp"
+	"strings"
+	"wb-task-L0/pkg/models"
+)
+
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors is the list of rule violations found in an order. It is returned
+// as an error so callers can pass it through the usual error paths and pick
+// it back up with errors.As.
+type Errors []Violation
+
+func (e Errors) Error() string {
+	parts := make([]string, 0, len(e))
+	for _, v := range e {
+		parts = append(parts, v.Field+": "+v.Message)
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+var (
+	localeRe = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+	phoneRe  = regexp.MustCompile(`^\+?[0-9]{7,15}$`)
+	zipRe    = regexp.MustCompile(`^[0-9A-Za-z -]{3,10}$`)
+)
+
+// amounts are stored as NUMERIC(12,2), allow for float rounding
+const moneyEpsilon = 0.01
+
+type checker struct {
+	errs Errors
+}
+
+func (c *checker) add(field, format string, args ...any) {
+	c.errs = append(c.errs, Violation{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		c.add(field, "is required")
+	}
+}
+
+func (c *checker) nonNegative(field string, value float64) {
+	if value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		c.add(field, "must be a non-negative number")
+	}
+}
+
+func (c *checker) sameOrder(field, value, orderUID string) {
+	if value != "" && value != orderUID {
+		c.add(field, "must match order_uid %q", orderUID)
+	}
+}
+
+// ValidateOrder checks required fields, formats, numeric ranges and
+// cross-field rules. It returns nil or an Errors value.
+func ValidateOrder(o *models.Order) error {
+	c := &checker{}
+
+	c.required("order_uid", o.OrderUID)
+	c.required("track_number", o.TrackNumber)
+	c.required("entry", o.Entry)
+	c.required("customer_id", o.CustomerID)
+	if o.Locale != "" && !localeRe.MatchString(o.Locale) {
+		c.add("locale", "must be a locale code like \"en\" or \"ru-RU\"")
+	}
+	if o.SmID < 0 {
+		c.add("sm_id", "must be non-negative")
+	}
+	if o.DateCreated.IsZero() {
+		c.add("date_created", "is required")
+	}
+
+	validateDelivery(c, o)
+	validatePayment(c, o)
+	validateItems(c, o)
+
+	if len(c.errs) == 0 {
+		return nil
+	}
+	return c.errs
+}
+
+func validateDelivery(c *checker, o *models.Order) {
+	d := o.Delivery
+	if d == (models.Delivery{}) {
+		c.add("delivery", "is required")
+		return
+	}
+
+	c.sameOrder("delivery.order_uid", d.OrderUID, o.OrderUID)
+	c.required("delivery.name", d.Name)
+	c.required("delivery.city", d.City)
+	c.required("delivery.address", d.Address)
+	if d.Phone == "" {
+		c.add("delivery.phone", "is required")
+	} else if !phoneRe.MatchString(d.Phone) {
+		c.add("delivery.phone", "must be a phone number in international format")
+	}
+	if d.Email != "" {
+		if addr, err := mail.ParseAddress(d.Email); err != nil || addr.Address != d.Email {
+			c.add("delivery.email", "must be a valid email address")
+		}
+	}
+	if d.Zip != "" && !zipRe.MatchString(d.Zip) {
+		c.add("delivery.zip", "must be a postal code")
+	}
+}
+
+func validatePayment(c *checker, o *models.Order) {
+	p := o.Payment
+	if p == (models.Payment{}) {
+		c.add("payment", "is required")
+		return
+	}
+
+	c.sameOrder("payment.order_uid", p.OrderUID, o.OrderUID)
+	c.required("payment.transaction", p.Transaction)
+	c.required("payment.provider", p.Provider)
+	if p.Currency == "" {
+		c.add("payment.currency", "is required")
+	} else if !currencies[p.Currency] {
+		c.add("payment.currency", "must be an ISO 4217 currency code")
+	}
+	if p.PaymentDt <= 0 {
+		c.add("payment.payment_dt", "must be a positive unix timestamp")
+	}
+
+	c.nonNegative("payment.amount", p.Amount)
+	c.nonNegative("payment.delivery_cost", p.DeliveryCost)
+	c.nonNegative("payment.goods_total", p.GoodsTotal)
+	c.nonNegative("payment.custom_fee", p.CustomFee)
+
+	total := p.GoodsTotal + p.DeliveryCost + p.CustomFee
+	if math.Abs(p.Amount-total) > moneyEpsilon {
+		c.add("payment.amount", "must equal goods_total + delivery_cost + custom_fee (%.2f)", total)
+	}
+}
+
+func validateItems(c *checker, o *models.Order) {
+	if len(o.Items) == 0 {
+		c.add("items", "must contain at least one item")
+		return
+	}
+
+	for i, it := range o.Items {
+		field := func(name string) string {
+			return fmt.Sprintf("items[%d].%s", i, name)
+		}
+
+		c.sameOrder(field("order_uid"), it.OrderUID, o.OrderUID)
+		c.required(field("name"), it.Name)
+		if it.ChrtID <= 0 {
+			c.add(field("chrt_id"), "must be positive")
+		}
+		if it.NmID < 0 {
+			c.add(field("nm_id"), "must be non-negative")
+		}
+		if it.TrackNumber != "" && it.TrackNumber != o.TrackNumber {
+			c.add(field("track_number"), "must match order track_number %q", o.TrackNumber)
+		}
+		c.nonNegative(field("price"), it.Price)
+		c.nonNegative(field("total_price"), it.TotalPrice)
+		if it.Sale < 0 || it.Sale > 100 {
+			c.add(field("sale"), "must be a percentage between 0 and 100")
+		}
+		if it.Status < 0 {
+			c.add(field("status"), "must be non-negative")
+		}
+	}
+}
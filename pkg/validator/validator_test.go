@@ -0,0 +1,92 @@
+package validator_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"wb-task-L0/pkg/models"
+	"wb-task-L0/pkg/validator"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validOrder() models.Order {
+	return models.Order{
+		OrderUID:    "order123",
+		TrackNumber: "track456",
+		Entry:       "WBIL",
+		Locale:      "en",
+		CustomerID:  "cust1",
+		DateCreated: time.Now(),
+		Delivery: models.Delivery{
+			DeliveryID: "del1",
+			OrderUID:   "order123",
+			Name:       "John",
+			Phone:      "+1234567890",
+			Zip:        "123456",
+			City:       "Moscow",
+			Address:    "Red Square, 1",
+			Email:      "john@example.com",
+		},
+		Payment: models.Payment{
+			PaymentID:    "pay1",
+			Transaction:  "tx123",
+			Currency:     "RUB",
+			Provider:     "wbpay",
+			Amount:       1200,
+			PaymentDt:    1735728000,
+			DeliveryCost: 200,
+			GoodsTotal:   1000,
+		},
+		Items: []models.Item{
+			{ItemID: "it1", ChrtID: 1, TrackNumber: "track456", Price: 500, Name: "item1", TotalPrice: 500},
+			{ItemID: "it2", ChrtID: 2, TrackNumber: "track456", Price: 500, Name: "item2", TotalPrice: 500},
+		},
+	}
+}
+
+func TestValidateOrder_Valid(t *testing.T) {
+	order := validOrder()
+	assert.NoError(t, validator.ValidateOrder(&order))
+}
+
+func TestValidateOrder_Violations(t *testing.T) {
+	order := validOrder()
+	order.OrderUID = ""
+	order.Locale = "english"
+	order.Delivery.Email = "not-an-email"
+	order.Payment.Currency = "XXX"
+	order.Payment.Amount = -1
+	order.Items[1].Price = -10
+	order.Items[1].TrackNumber = "other"
+
+	err := validator.ValidateOrder(&order)
+	require.Error(t, err)
+
+	var verrs validator.Errors
+	require.True(t, errors.As(err, &verrs))
+
+	fields := make(map[string]bool)
+	for _, v := range verrs {
+		fields[v.Field] = true
+	}
+	for _, f := range []string{
+		"order_uid", "locale", "delivery.email", "payment.currency",
+		"payment.amount", "items[1].price", "items[1].track_number",
+	} {
+		assert.True(t, fields[f], "expected violation for %s", f)
+	}
+	assert.False(t, fields["items[0].price"])
+}
+
+func TestValidateOrder_MissingSections(t *testing.T) {
+	order := validOrder()
+	order.Delivery = models.Delivery{}
+	order.Payment = models.Payment{}
+	order.Items = nil
+
+	var verrs validator.Errors
+	require.True(t, errors.As(validator.ValidateOrder(&order), &verrs))
+	assert.ElementsMatch(t, []string{"delivery", "payment", "items"}, []string{verrs[0].Field, verrs[1].Field, verrs[2].Field})
+}